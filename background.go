@@ -0,0 +1,83 @@
+package usrbin
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/usrbinapp/usrbin-go/pkg/updatechecker"
+)
+
+var (
+	ErrCheckNotFinished = errors.New("update check not finished")
+)
+
+// BackgroundCheck is an update check that's running in a goroutine
+type BackgroundCheck struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	// updateInfo and err are set before done is closed
+	updateInfo *updatechecker.UpdateInfo
+	err        error
+}
+
+// CheckInBackground will start GetUpdateInfo in a goroutine and return
+// right away. call Result on the handle, usually as the app exits, to get
+// the update info if the check has finished by then. the cache is used the
+// same way as GetUpdateInfo
+func (s SDK) CheckInBackground() *BackgroundCheck {
+	return s.CheckInBackgroundContext(context.Background())
+}
+
+// CheckInBackgroundContext is CheckInBackground, stopping the check when
+// ctx is done
+func (s SDK) CheckInBackgroundContext(ctx context.Context) *BackgroundCheck {
+	ctx, cancel := context.WithCancel(ctx)
+
+	b := &BackgroundCheck{
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	go func() {
+		defer close(b.done)
+		defer cancel()
+
+		b.updateInfo, b.err = s.GetUpdateInfoContext(ctx)
+	}()
+
+	return b
+}
+
+// Result will wait up to wait for the check to finish and return its result.
+// when the check doesn't finish in time, it's cancelled and
+// ErrCheckNotFinished is returned
+func (b *BackgroundCheck) Result(wait time.Duration) (*updatechecker.UpdateInfo, error) {
+	select {
+	case <-b.done:
+		return b.updateInfo, b.err
+	default:
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-b.done:
+		return b.updateInfo, b.err
+	case <-timer.C:
+		b.cancel()
+		return nil, ErrCheckNotFinished
+	}
+}
+
+// Cancel will stop the check if it's still running
+func (b *BackgroundCheck) Cancel() {
+	b.cancel()
+}
+
+// Done will return a channel that's closed once the check has finished
+func (b *BackgroundCheck) Done() <-chan struct{} {
+	return b.done
+}
@@ -0,0 +1,97 @@
+package usrbin
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/usrbinapp/usrbin-go/pkg/updatechecker"
+)
+
+// delayedUpdateChecker returns version after delay, unless the context
+// is done first
+type delayedUpdateChecker struct {
+	version string
+	delay   time.Duration
+}
+
+func (c delayedUpdateChecker) GetLatestVersion(timeout time.Duration) (*updatechecker.VersionInfo, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return c.GetLatestVersionContext(ctx)
+}
+
+func (c delayedUpdateChecker) GetLatestVersionContext(ctx context.Context) (*updatechecker.VersionInfo, error) {
+	select {
+	case <-time.After(c.delay):
+		return &updatechecker.VersionInfo{Version: c.version}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (c delayedUpdateChecker) DownloadVersion(version string, requireChecksumMatch bool) (string, error) {
+	return "", nil
+}
+
+func (c delayedUpdateChecker) DownloadVersionContext(ctx context.Context, version string, requireChecksumMatch bool) (string, error) {
+	return "", nil
+}
+
+func Test_CheckInBackground(t *testing.T) {
+	tests := []struct {
+		name        string
+		delay       time.Duration
+		wait        time.Duration
+		wantVersion string
+		wantErr     error
+	}{
+		{
+			name:        "finished in time",
+			delay:       0,
+			wait:        time.Second * 5,
+			wantVersion: "1.1.0",
+		},
+		{
+			name:    "not finished in time",
+			delay:   time.Minute,
+			wait:    time.Millisecond * 50,
+			wantErr: ErrCheckNotFinished,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sdk := SDK{
+				version:       "1.0.0",
+				updateChecker: delayedUpdateChecker{version: "1.1.0", delay: tt.delay},
+				httpTimeout:   time.Minute * 5,
+			}
+
+			check := sdk.CheckInBackground()
+			got, err := check.Result(tt.wait)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+
+				// the check is cancelled, rather than left running
+				select {
+				case <-check.Done():
+				case <-time.After(time.Second * 5):
+					t.Fatal("check wasn't cancelled")
+				}
+				return
+			}
+
+			require.NoError(t, err)
+			require.NotNil(t, got)
+			assert.Equal(t, tt.wantVersion, got.LatestVersion)
+
+			// the result can be read again
+			again, err := check.Result(0)
+			require.NoError(t, err)
+			assert.Equal(t, got, again)
+		})
+	}
+}
@@ -0,0 +1,227 @@
+package usrbin
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver"
+	"github.com/minio/selfupdate"
+	"github.com/pkg/errors"
+)
+
+const (
+	// backupInfix is between the executable's name and the version in the
+	// name of a backup, like mytool.old-1.4.0
+	backupInfix = ".old-"
+
+	// defaultBackupRetention keeps the backup that Rollback restores
+	defaultBackupRetention = 1
+)
+
+var (
+	ErrNoBackup = errors.New("no backup to roll back to")
+)
+
+// backup is an executable that an upgrade replaced
+type backup struct {
+	path    string
+	version string
+	modTime time.Time
+}
+
+// Rollback will replace the executable with the backup of the highest
+// version, undoing the last upgrade, and return the version that was restored. the backup is
+// removed once it has been restored, so calling Rollback again goes back
+// another version if there's an older backup. no requests are made.
+// ErrNoBackup is returned when there isn't a backup
+func (s SDK) Rollback() (string, error) {
+	exe, err := executablePath()
+	if err != nil {
+		return "", errors.Wrap(err, "executable path")
+	}
+
+	return s.rollback(exe)
+}
+
+func (s SDK) rollback(exe string) (string, error) {
+	backups, err := listBackups(exe)
+	if err != nil {
+		return "", errors.Wrap(err, "list backups")
+	}
+
+	if len(backups) == 0 {
+		return "", ErrNoBackup
+	}
+	newest := backups[0]
+
+	f, err := os.Open(newest.path)
+	if err != nil {
+		return "", errors.Wrap(err, "open backup")
+	}
+
+	err = selfupdate.Apply(f, selfupdate.Options{TargetPath: exe})
+	f.Close()
+	if err != nil {
+		return "", errors.Wrap(err, "apply backup")
+	}
+
+	if err := os.Remove(newest.path); err != nil {
+		s.logf("failed to remove restored backup: %v", err)
+	}
+
+	return newest.version, nil
+}
+
+// replaceExecutable will replace exe with newVersion. unless backups are
+// turned off, exe is kept as a backup for the current version, and the
+// backups beyond the retention are removed
+func (s SDK) replaceExecutable(exe string, newVersion io.Reader) error {
+	opts := selfupdate.Options{
+		TargetPath: exe,
+	}
+	if s.backupRetention > 0 {
+		opts.OldSavePath = backupPath(exe, s.version)
+	}
+
+	if err := selfupdate.Apply(newVersion, opts); err != nil {
+		return err
+	}
+
+	if opts.OldSavePath == "" {
+		return nil
+	}
+
+	// the backup keeps the modification time of the executable, so set it
+	// to when it was replaced to make the newest backup the last one
+	now := time.Now()
+	if err := os.Chtimes(opts.OldSavePath, now, now); err != nil {
+		s.logf("failed to set backup modification time: %v", err)
+	}
+
+	if err := pruneBackups(exe, s.backupRetention); err != nil {
+		s.logf("failed to remove old backups: %v", err)
+	}
+
+	return nil
+}
+
+// executablePath will return the path of the running executable, with
+// symlinks resolved so that the executable is replaced instead of the link
+func executablePath() (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.EvalSymlinks(exe)
+}
+
+// backupPath will return where exe is kept when it's replaced, for version
+func backupPath(exe string, version string) string {
+	version = strings.NewReplacer("/", "_", "\\", "_").Replace(version)
+	return exe + backupInfix + version
+}
+
+// listBackups will return the backups of exe, highest version first. the
+// version is read from the backup's name, so that a filesystem with coarse
+// timestamps can't reorder them, and the modification time breaks a tie.
+// backups whose version isn't semver are sorted after the others
+func listBackups(exe string) ([]backup, error) {
+	entries, err := ioutil.ReadDir(filepath.Dir(exe))
+	if err != nil {
+		return nil, errors.Wrap(err, "read dir")
+	}
+
+	prefix := filepath.Base(exe) + backupInfix
+
+	backups := []backup{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasPrefix(entry.Name(), prefix) {
+			continue
+		}
+
+		backups = append(backups, backup{
+			path:    filepath.Join(filepath.Dir(exe), entry.Name()),
+			version: strings.TrimPrefix(entry.Name(), prefix),
+			modTime: entry.ModTime(),
+		})
+	}
+
+	parsed := make([]*semver.Version, len(backups))
+	for i, b := range backups {
+		parsed[i] = backupSemver(b.version)
+	}
+
+	sort.Sort(backupsByVersion{backups: backups, parsed: parsed})
+
+	return backups, nil
+}
+
+// backupSemver will return the semver of a backup's version, ignoring
+// a pinned digest, or nil when it isn't semver
+func backupSemver(version string) *semver.Version {
+	if i := strings.Index(version, "@"); i != -1 {
+		version = version[:i]
+	}
+
+	parsed, err := semver.NewVersion(version)
+	if err != nil {
+		return nil
+	}
+
+	return parsed
+}
+
+// backupsByVersion sorts backups by their parsed semver, highest first,
+// then by modification time, newest first
+type backupsByVersion struct {
+	backups []backup
+	parsed  []*semver.Version
+}
+
+func (b backupsByVersion) Len() int {
+	return len(b.backups)
+}
+
+func (b backupsByVersion) Swap(i, j int) {
+	b.backups[i], b.backups[j] = b.backups[j], b.backups[i]
+	b.parsed[i], b.parsed[j] = b.parsed[j], b.parsed[i]
+}
+
+func (b backupsByVersion) Less(i, j int) bool {
+	a, c := b.parsed[i], b.parsed[j]
+	if a != nil && c != nil && !a.Equal(c) {
+		return a.GreaterThan(c)
+	}
+	if (a == nil) != (c == nil) {
+		return a != nil
+	}
+
+	return b.backups[i].modTime.After(b.backups[j].modTime)
+}
+
+// pruneBackups will remove the backups of exe beyond the keep with the
+// highest versions
+func pruneBackups(exe string, keep int) error {
+	backups, err := listBackups(exe)
+	if err != nil {
+		return err
+	}
+
+	if len(backups) <= keep {
+		return nil
+	}
+
+	for _, b := range backups[keep:] {
+		if err := os.Remove(b.path); err != nil {
+			return errors.Wrap(err, "remove backup")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,111 @@
+package usrbin
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_replaceExecutableAndRollback(t *testing.T) {
+	req := require.New(t)
+
+	dir, err := ioutil.TempDir("", "usrbin")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+
+	exe := filepath.Join(dir, "mytool")
+	req.NoError(ioutil.WriteFile(exe, []byte("1.0.0"), 0755))
+
+	for _, upgrade := range []struct{ from, to string }{
+		{from: "1.0.0", to: "1.1.0"},
+		{from: "1.1.0", to: "1.2.0"},
+		{from: "1.2.0", to: "1.3.0"},
+	} {
+		sdk := SDK{version: upgrade.from, backupRetention: 2}
+		req.NoError(sdk.replaceExecutable(exe, bytes.NewReader([]byte(upgrade.to))))
+		assertContent(t, exe, upgrade.to)
+	}
+
+	backups, err := listBackups(exe)
+	req.NoError(err)
+	req.Len(backups, 2)
+	assert.Equal(t, "1.2.0", backups[0].version)
+	assert.Equal(t, "1.1.0", backups[1].version)
+	assertContent(t, filepath.Join(dir, "mytool.old-1.2.0"), "1.2.0")
+
+	sdk := SDK{version: "1.3.0"}
+
+	restored, err := sdk.rollback(exe)
+	req.NoError(err)
+	assert.Equal(t, "1.2.0", restored)
+	assertContent(t, exe, "1.2.0")
+
+	restored, err = sdk.rollback(exe)
+	req.NoError(err)
+	assert.Equal(t, "1.1.0", restored)
+	assertContent(t, exe, "1.1.0")
+
+	_, err = sdk.rollback(exe)
+	assert.ErrorIs(t, err, ErrNoBackup)
+}
+
+func Test_replaceExecutableWithoutBackups(t *testing.T) {
+	req := require.New(t)
+
+	dir, err := ioutil.TempDir("", "usrbin")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+
+	exe := filepath.Join(dir, "mytool")
+	req.NoError(ioutil.WriteFile(exe, []byte("1.0.0"), 0755))
+
+	sdk := SDK{version: "1.0.0", backupRetention: 0}
+	req.NoError(sdk.replaceExecutable(exe, bytes.NewReader([]byte("1.1.0"))))
+	assertContent(t, exe, "1.1.0")
+
+	backups, err := listBackups(exe)
+	req.NoError(err)
+	assert.Empty(t, backups)
+}
+
+func Test_listBackupsSameModTime(t *testing.T) {
+	req := require.New(t)
+
+	dir, err := ioutil.TempDir("", "usrbin")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+
+	exe := filepath.Join(dir, "mytool")
+	req.NoError(ioutil.WriteFile(exe, []byte("1.3.0"), 0755))
+
+	// a filesystem with coarse timestamps gives every backup the same time
+	modTime := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	for _, version := range []string{"1.1.0", "not-a-version", "1.10.0", "v1.2.0@sha256:abc", "1.2.0-beta.1"} {
+		path := backupPath(exe, version)
+		req.NoError(ioutil.WriteFile(path, []byte(version), 0755))
+		req.NoError(os.Chtimes(path, modTime, modTime))
+	}
+
+	backups, err := listBackups(exe)
+	req.NoError(err)
+
+	got := []string{}
+	for _, b := range backups {
+		got = append(got, b.version)
+	}
+	assert.Equal(t, []string{"1.10.0", "v1.2.0@sha256:abc", "1.2.0-beta.1", "1.1.0", "not-a-version"}, got)
+}
+
+func assertContent(t *testing.T, path string, want string) {
+	t.Helper()
+
+	b, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, want, string(b))
+}
@@ -0,0 +1,128 @@
+package usrbin
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/usrbinapp/usrbin-go/pkg/updatechecker"
+)
+
+const (
+	updateInfoCacheFile = "updateinfo.json"
+)
+
+// updateInfoCache stores the result of the last update check on disk, so
+// that every run of the app doesn't have to make a request
+type updateInfoCache struct {
+	dir string
+	ttl time.Duration
+}
+
+// updateInfoCacheKey is what the check was made for. a cached result
+// doesn't apply when any of it is different
+type updateInfoCacheKey struct {
+	// CurrentVersion is the version of the app that made the check
+	CurrentVersion string `json:"currentVersion"`
+
+	Channel           string `json:"channel,omitempty"`
+	VersionConstraint string `json:"versionConstraint,omitempty"`
+}
+
+// cachedUpdateInfo is the document in the cache dir
+type cachedUpdateInfo struct {
+	updateInfoCacheKey
+
+	CheckedAt time.Time `json:"checkedAt"`
+
+	// UpdateInfo is nil when there was no newer version
+	UpdateInfo *updatechecker.UpdateInfo `json:"updateInfo"`
+}
+
+// defaultCacheDir will return the directory that the cache for app is
+// stored in, under the user's cache dir
+func defaultCacheDir(app string) (string, error) {
+	userCacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", errors.Wrap(err, "user cache dir")
+	}
+
+	return filepath.Join(userCacheDir, "usrbin", app), nil
+}
+
+// read will return the cached update info, and true, when there is one
+// for key that was checked less than the ttl before now
+func (c *updateInfoCache) read(key updateInfoCacheKey, now time.Time) (*updatechecker.UpdateInfo, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(c.dir, updateInfoCacheFile))
+	if err != nil {
+		return nil, false
+	}
+
+	cached := cachedUpdateInfo{}
+	if err := json.Unmarshal(b, &cached); err != nil {
+		return nil, false
+	}
+
+	if cached.updateInfoCacheKey != key {
+		return nil, false
+	}
+
+	if now.Sub(cached.CheckedAt) >= c.ttl || cached.CheckedAt.After(now) {
+		return nil, false
+	}
+
+	return cached.UpdateInfo, true
+}
+
+func (c *updateInfoCache) write(key updateInfoCacheKey, checkedAt time.Time, updateInfo *updatechecker.UpdateInfo) error {
+	if c == nil {
+		return nil
+	}
+
+	b, err := json.Marshal(cachedUpdateInfo{
+		updateInfoCacheKey: key,
+		CheckedAt:          checkedAt,
+		UpdateInfo:         updateInfo,
+	})
+	if err != nil {
+		return errors.Wrap(err, "marshal update info")
+	}
+
+	return writeCacheFile(c.dir, updateInfoCacheFile, b)
+}
+
+// writeCacheFile will write b to name in the cache dir, creating the dir
+func writeCacheFile(dir string, name string, b []byte) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return errors.Wrap(err, "create cache dir")
+	}
+
+	// write to a temp file and rename, so that another run of the
+	// app never reads a partial file
+	tmpFile, err := ioutil.TempFile(dir, name)
+	if err != nil {
+		return errors.Wrap(err, "create temp file")
+	}
+	defer os.Remove(tmpFile.Name())
+
+	if _, err := tmpFile.Write(b); err != nil {
+		tmpFile.Close()
+		return errors.Wrap(err, "write temp file")
+	}
+	if err := tmpFile.Close(); err != nil {
+		return errors.Wrap(err, "close temp file")
+	}
+
+	if err := os.Rename(tmpFile.Name(), filepath.Join(dir, name)); err != nil {
+		return errors.Wrap(err, "rename temp file")
+	}
+
+	return nil
+}
@@ -0,0 +1,135 @@
+package usrbin
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/usrbinapp/usrbin-go/pkg/updatechecker"
+)
+
+func Test_updateInfoCache(t *testing.T) {
+	checkedAt := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	updateInfo := &updatechecker.UpdateInfo{
+		LatestVersion: "1.1.0",
+		CheckedAt:     &checkedAt,
+	}
+
+	tests := []struct {
+		name       string
+		updateInfo *updatechecker.UpdateInfo
+		key        updateInfoCacheKey
+		now        time.Time
+		want       *updatechecker.UpdateInfo
+		wantOK     bool
+	}{
+		{
+			name:       "fresh",
+			updateInfo: updateInfo,
+			key:        updateInfoCacheKey{CurrentVersion: "1.0.0"},
+			now:        checkedAt.Add(time.Hour),
+			want:       updateInfo,
+			wantOK:     true,
+		},
+		{
+			name:       "fresh, no update",
+			updateInfo: nil,
+			key:        updateInfoCacheKey{CurrentVersion: "1.0.0"},
+			now:        checkedAt.Add(time.Hour),
+			want:       nil,
+			wantOK:     true,
+		},
+		{
+			name:       "expired",
+			updateInfo: updateInfo,
+			key:        updateInfoCacheKey{CurrentVersion: "1.0.0"},
+			now:        checkedAt.Add(time.Hour * 24),
+			wantOK:     false,
+		},
+		{
+			name:       "checked by another version",
+			updateInfo: updateInfo,
+			key:        updateInfoCacheKey{CurrentVersion: "1.1.0"},
+			now:        checkedAt.Add(time.Hour),
+			wantOK:     false,
+		},
+		{
+			name:       "checked on another channel",
+			updateInfo: updateInfo,
+			key:        updateInfoCacheKey{CurrentVersion: "1.0.0", Channel: "beta"},
+			now:        checkedAt.Add(time.Hour),
+			wantOK:     false,
+		},
+		{
+			name:       "checked with another constraint",
+			updateInfo: updateInfo,
+			key:        updateInfoCacheKey{CurrentVersion: "1.0.0", VersionConstraint: "<2.0"},
+			now:        checkedAt.Add(time.Hour),
+			wantOK:     false,
+		},
+		{
+			name:       "checked in the future",
+			updateInfo: updateInfo,
+			key:        updateInfoCacheKey{CurrentVersion: "1.0.0"},
+			now:        checkedAt.Add(-time.Hour),
+			wantOK:     false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := require.New(t)
+
+			dir, err := ioutil.TempDir("", "usrbin")
+			req.NoError(err)
+			defer os.RemoveAll(dir)
+
+			c := &updateInfoCache{
+				dir: filepath.Join(dir, "app"),
+				ttl: time.Hour * 24,
+			}
+
+			err = c.write(updateInfoCacheKey{CurrentVersion: "1.0.0"}, checkedAt, tt.updateInfo)
+			req.NoError(err)
+
+			got, ok := c.read(tt.key, tt.now)
+			assert.Equal(t, tt.wantOK, ok)
+			if !tt.wantOK {
+				return
+			}
+
+			if tt.want == nil {
+				assert.Nil(t, got)
+				return
+			}
+
+			req.NotNil(got)
+			assert.Equal(t, tt.want.LatestVersion, got.LatestVersion)
+			assert.True(t, tt.want.CheckedAt.Equal(*got.CheckedAt))
+		})
+	}
+}
+
+func Test_updateInfoCacheMissing(t *testing.T) {
+	dir, err := ioutil.TempDir("", "usrbin")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c := &updateInfoCache{
+		dir: dir,
+		ttl: time.Hour,
+	}
+
+	key := updateInfoCacheKey{CurrentVersion: "1.0.0"}
+
+	_, ok := c.read(key, time.Now())
+	assert.False(t, ok)
+
+	var nilCache *updateInfoCache
+	_, ok = nilCache.read(key, time.Now())
+	assert.False(t, ok)
+	assert.NoError(t, nilCache.write(key, time.Now(), nil))
+}
@@ -0,0 +1,175 @@
+package usrbin
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/Masterminds/semver"
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrHealthCheckFailed = errors.New("health check failed")
+)
+
+// HealthCheck runs the downloaded executable before it replaces the
+// current one, so that an executable that can't run, like one built for
+// another arch, is never installed
+type HealthCheck struct {
+	// Args are passed to the downloaded executable, such as --version or
+	// a health subcommand. the check fails when it exits non-zero
+	Args []string
+
+	// ExpectVersion fails the check when the output (stdout and stderr)
+	// doesn't contain the version being installed as a word, like "1.4.0" or
+	// "v1.4.0"
+	ExpectVersion bool
+
+	// Timeout is how long the executable can run for. 0 doesn't limit it
+	Timeout time.Duration
+}
+
+// DefaultHealthCheck runs the executable with --version, and expects
+// it to print the version being installed
+var DefaultHealthCheck = HealthCheck{
+	Args:          []string{"--version"},
+	ExpectVersion: true,
+	Timeout:       time.Second * 10,
+}
+
+// run will run the executable at path, which is version. an error that
+// wraps ErrHealthCheckFailed is returned when the check fails
+func (h HealthCheck) run(ctx context.Context, path string, version string) error {
+	if h.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.Timeout)
+		defer cancel()
+	}
+
+	// the downloaded file isn't always executable
+	if err := os.Chmod(path, 0755); err != nil {
+		return errors.Wrap(err, "make executable")
+	}
+
+	// windows only runs a file with an executable extension, and the
+	// downloaded file doesn't have one, so it's run with the extension
+	// of the current executable
+	ext := ""
+	if exe, err := os.Executable(); err == nil {
+		ext = filepath.Ext(exe)
+	}
+
+	runPath, cleanup, err := withExtension(path, ext)
+	if err != nil {
+		return errors.Wrap(err, "copy with extension")
+	}
+	defer cleanup()
+
+	output := bytes.Buffer{}
+	cmd := exec.CommandContext(ctx, runPath, h.Args...)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	// a child process that the executable started can keep the output
+	// open after the executable has been killed
+	cmd.WaitDelay = time.Second
+
+	if err := cmd.Run(); err != nil {
+		return errors.Wrapf(ErrHealthCheckFailed, "run %s: %v: %s", strings.Join(h.Args, " "), err, strings.TrimSpace(output.String()))
+	}
+
+	if !h.ExpectVersion {
+		return nil
+	}
+
+	expected := expectedVersion(version)
+	if !containsVersion(output.String(), expected) {
+		return errors.Wrapf(ErrHealthCheckFailed, "expected version %s in output: %s", expected, strings.TrimSpace(output.String()))
+	}
+
+	return nil
+}
+
+// withExtension will return path when it already ends with ext, otherwise
+// a copy of it in a temp dir that does. cleanup removes the copy
+func withExtension(path string, ext string) (string, func(), error) {
+	if ext == "" || strings.EqualFold(filepath.Ext(path), ext) {
+		return path, func() {}, nil
+	}
+
+	dir, err := ioutil.TempDir("", "usrbin")
+	if err != nil {
+		return "", nil, errors.Wrap(err, "create temp dir")
+	}
+	cleanup := func() {
+		os.RemoveAll(dir)
+	}
+
+	src, err := os.Open(path)
+	if err != nil {
+		cleanup()
+		return "", nil, errors.Wrap(err, "open file")
+	}
+	defer src.Close()
+
+	copyPath := filepath.Join(dir, filepath.Base(path)+ext)
+	dst, err := os.OpenFile(copyPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0755)
+	if err != nil {
+		cleanup()
+		return "", nil, errors.Wrap(err, "create copy")
+	}
+
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		cleanup()
+		return "", nil, errors.Wrap(err, "copy file")
+	}
+	if err := dst.Close(); err != nil {
+		cleanup()
+		return "", nil, errors.Wrap(err, "close copy")
+	}
+
+	return copyPath, cleanup, nil
+}
+
+// containsVersion will return true when one of the words in output is
+// version, with or without a leading v, so that "11.4.0" or "1.4.0-rc.1"
+// isn't mistaken for "1.4.0"
+func containsVersion(output string, version string) bool {
+	words := strings.FieldsFunc(output, func(r rune) bool {
+		return unicode.IsSpace(r) || strings.ContainsRune(`,;:()[]{}"'=/`, r)
+	})
+
+	for _, word := range words {
+		word = strings.TrimRight(word, ".")
+		word = strings.TrimPrefix(strings.TrimPrefix(word, "v"), "V")
+		if word == version {
+			return true
+		}
+	}
+
+	return false
+}
+
+// expectedVersion will return the version that the executable is expected
+// to print for version, without a leading v or a pinned digest, so that
+// an app that prints "mytool 1.4.0" passes for the release tagged v1.4.0
+func expectedVersion(version string) string {
+	if i := strings.Index(version, "@"); i != -1 {
+		version = version[:i]
+	}
+
+	if parsed, err := semver.NewVersion(version); err == nil {
+		return parsed.String()
+	}
+
+	return version
+}
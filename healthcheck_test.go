@@ -0,0 +1,154 @@
+package usrbin
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_HealthCheck(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the test executables are shell scripts")
+	}
+
+	tests := []struct {
+		name    string
+		script  string
+		check   HealthCheck
+		version string
+		wantErr bool
+	}{
+		{
+			name:    "prints the version",
+			script:  "#!/bin/sh\necho \"mytool version 1.4.0\"\n",
+			check:   DefaultHealthCheck,
+			version: "v1.4.0",
+		},
+		{
+			name:    "pinned to a digest",
+			script:  "#!/bin/sh\necho \"1.4.0\"\n",
+			check:   DefaultHealthCheck,
+			version: "v1.4.0@sha256:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+		},
+		{
+			name:    "prints another version",
+			script:  "#!/bin/sh\necho \"mytool version 1.3.0\"\n",
+			check:   DefaultHealthCheck,
+			version: "v1.4.0",
+			wantErr: true,
+		},
+		{
+			name:    "prints a version that contains it",
+			script:  "#!/bin/sh\necho \"mytool version 11.4.0\"\n",
+			check:   DefaultHealthCheck,
+			version: "v1.4.0",
+			wantErr: true,
+		},
+		{
+			name:    "exits non-zero",
+			script:  "#!/bin/sh\necho \"1.4.0\"\nexit 1\n",
+			check:   DefaultHealthCheck,
+			version: "v1.4.0",
+			wantErr: true,
+		},
+		{
+			name:    "can't be executed",
+			script:  "\x7fELF not for this arch",
+			check:   DefaultHealthCheck,
+			version: "v1.4.0",
+			wantErr: true,
+		},
+		{
+			name:    "health subcommand",
+			script:  "#!/bin/sh\n[ \"$1\" = \"health\" ]\n",
+			check:   HealthCheck{Args: []string{"health"}},
+			version: "v1.4.0",
+		},
+		{
+			name:    "timeout",
+			script:  "#!/bin/sh\nsleep 5\n",
+			check:   HealthCheck{Args: []string{"health"}, Timeout: time.Millisecond * 100},
+			version: "v1.4.0",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := require.New(t)
+
+			dir, err := ioutil.TempDir("", "usrbin")
+			req.NoError(err)
+			defer os.RemoveAll(dir)
+
+			path := filepath.Join(dir, "mytool")
+			req.NoError(ioutil.WriteFile(path, []byte(tt.script), 0644))
+
+			err = tt.check.run(context.Background(), path, tt.version)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrHealthCheckFailed)
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func Test_containsVersion(t *testing.T) {
+	tests := []struct {
+		output string
+		want   bool
+	}{
+		{output: "1.4.0", want: true},
+		{output: "mytool version v1.4.0\n", want: true},
+		{output: "mytool (1.4.0, abc123)", want: true},
+		{output: "version=1.4.0.", want: true},
+		{output: "mytool/1.4.0", want: true},
+		{output: "mytool 11.4.0", want: false},
+		{output: "mytool 1.4.01", want: false},
+		{output: "mytool 1.4.0-rc.1", want: false},
+		{output: "mytool", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.output, func(t *testing.T) {
+			assert.Equal(t, tt.want, containsVersion(tt.output, "1.4.0"))
+		})
+	}
+}
+
+func Test_withExtension(t *testing.T) {
+	req := require.New(t)
+
+	dir, err := ioutil.TempDir("", "usrbin")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "mytool")
+	req.NoError(ioutil.WriteFile(path, []byte("new version"), 0755))
+
+	got, cleanup, err := withExtension(path, "")
+	req.NoError(err)
+	cleanup()
+	assert.Equal(t, path, got)
+
+	got, cleanup, err = withExtension(path, ".exe")
+	req.NoError(err)
+	assert.Equal(t, "mytool.exe", filepath.Base(got))
+
+	contents, err := ioutil.ReadFile(got)
+	req.NoError(err)
+	assert.Equal(t, "new version", string(contents))
+
+	cleanup()
+	_, err = os.Stat(got)
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(path)
+	assert.NoError(t, err)
+}
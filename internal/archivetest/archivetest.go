@@ -0,0 +1,43 @@
+// Package archivetest builds the archives that the update checker tests
+// serve for download
+package archivetest
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"github.com/usrbinapp/usrbin-go/pkg/archive"
+)
+
+// CreateTGZWithExecutable will return a tgz that holds contents in a file
+// named like the running executable, which is the file that
+// archive.FindProbableFileInWhatMightBeAnArchive picks, along with the
+// sha256 of the tgz
+func CreateTGZWithExecutable(contents []byte) ([]byte, string, error) {
+	dir, err := ioutil.TempDir("", "usrbin")
+	if err != nil {
+		return nil, "", errors.Wrap(err, "create temp dir")
+	}
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, filepath.Base(os.Args[0])), contents, 0755); err != nil {
+		return nil, "", errors.Wrap(err, "write file")
+	}
+
+	archivePath, err := archive.CreateTGZFileFromDir(dir)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "create tgz")
+	}
+	defer os.Remove(archivePath)
+
+	archiveContents, err := ioutil.ReadFile(archivePath)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "read tgz")
+	}
+
+	return archiveContents, fmt.Sprintf("%x", sha256.Sum256(archiveContents)), nil
+}
@@ -0,0 +1,113 @@
+package archive
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"github.com/usrbinapp/usrbin-go/pkg/logger"
+)
+
+var (
+	ErrUnknownArchiveType = errors.New("unknown archive type")
+)
+
+// FindProbableFileInWhatMightBeAnArchive will look inside the file at path and,
+// if it's an archive, extract the file that is most likely the binary
+// for the current process to a temp file. the caller must delete the
+// returned file
+func FindProbableFileInWhatMightBeAnArchive(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", errors.Wrap(err, "open file")
+	}
+
+	defer func() {
+		if err := f.Close(); err != nil {
+			logger.Error(err)
+		}
+	}()
+
+	// check if it's a gzip file
+	_, err = gzip.NewReader(f)
+	if err == nil {
+		return findProbableFileInGzip(path)
+	}
+
+	return "", ErrUnknownArchiveType
+}
+
+func findProbableFileInGzip(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", errors.Wrap(err, "open file")
+	}
+
+	defer func() {
+		if err := f.Close(); err != nil {
+			logger.Error(err)
+		}
+	}()
+
+	gzr, err := gzip.NewReader(f)
+	if err != nil {
+		return "", errors.Wrap(err, "open gzip file")
+	}
+
+	tr := tar.NewReader(gzr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return "", errors.Wrap(err, "read next file")
+		}
+
+		if header.Typeflag == tar.TypeReg {
+			// if the file is executable and matches the name of the current process
+			// then it's almost certainly the file we want
+
+			if isLikelyFile(header.Mode, header.Name, filepath.Base(os.Args[0])) {
+				tmpFile, err := ioutil.TempFile("", "usrbin")
+				if err != nil {
+					return "", errors.Wrap(err, "create temp file")
+				}
+
+				defer func() {
+					if err := tmpFile.Close(); err != nil {
+						logger.Error(err)
+					}
+				}()
+
+				if _, err := io.Copy(tmpFile, tr); err != nil {
+					return "", errors.Wrap(err, "copy file")
+				}
+
+				// set the mode on the file to match
+				if err := os.Chmod(tmpFile.Name(), os.FileMode(header.Mode)); err != nil {
+					return "", errors.Wrap(err, "set file mode")
+				}
+
+				return tmpFile.Name(), nil
+			}
+		}
+	}
+
+	return "", errors.New("unable to find matching file in archive")
+}
+
+func isLikelyFile(mode int64, name string, currentExecutableName string) bool {
+	if mode&0111 != 0 {
+		if currentExecutableName == filepath.Base(name) {
+			return true
+		}
+	}
+
+	return false
+}
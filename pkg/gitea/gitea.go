@@ -0,0 +1,344 @@
+package gitea
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver"
+	"github.com/pkg/errors"
+	"github.com/usrbinapp/usrbin-go/pkg/release"
+	"github.com/usrbinapp/usrbin-go/pkg/retry"
+	"github.com/usrbinapp/usrbin-go/pkg/updatechecker"
+)
+
+const (
+	CodebergHost = "https://codeberg.org"
+)
+
+var (
+	ErrReleaseNotFound = errors.New("release not found")
+	ErrTimeoutExceeded = release.ErrTimeoutExceeded
+)
+
+// GiteaUpdateChecker reads releases from a Gitea or Forgejo instance
+// (including Codeberg)
+type GiteaUpdateChecker struct {
+	timeout time.Duration
+
+	host string
+
+	httpClient  *http.Client
+	retryPolicy retry.Policy
+
+	channel           string
+	versionConstraint *semver.Constraints
+
+	parsedRepo struct {
+		owner string
+		repo  string
+	}
+}
+
+var _ updatechecker.UpdateChecker = (*GiteaUpdateChecker)(nil)
+var _ updatechecker.ContextUpdateChecker = (*GiteaUpdateChecker)(nil)
+var _ updatechecker.VersionLister = (*GiteaUpdateChecker)(nil)
+
+// Option is a functional option for configuring the update checker
+type Option func(*GiteaUpdateChecker)
+
+// WithHTTPClient will make all requests, including downloads, with the
+// http client passed in instead of http.DefaultClient
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *GiteaUpdateChecker) {
+		if httpClient != nil {
+			c.httpClient = httpClient
+		}
+	}
+}
+
+// WithRetryPolicy will set how failed requests are retried, instead of
+// retry.DefaultPolicy
+func WithRetryPolicy(policy retry.Policy) Option {
+	return func(c *GiteaUpdateChecker) {
+		c.retryPolicy = policy
+	}
+}
+
+// WithChannel will offer the releases in the channel (stable, rc, beta,
+// alpha, nightly or any other name). see updatechecker.InChannel. releases
+// that are flagged as prereleases are never on the stable channel
+func WithChannel(channel string) Option {
+	return func(c *GiteaUpdateChecker) {
+		c.channel = channel
+	}
+}
+
+// WithVersionConstraint will only offer the releases that satisfy
+// constraint. see updatechecker.SatisfiesConstraint
+func WithVersionConstraint(constraint *semver.Constraints) Option {
+	return func(c *GiteaUpdateChecker) {
+		c.versionConstraint = constraint
+	}
+}
+
+type giteaAsset struct {
+	ID                 int    `json:"id"`
+	Name               string `json:"name"`
+	Size               int    `json:"size"`
+	BrowserDownloadURL string `json:"browser_download_url"`
+}
+
+type giteaReleaseInfo struct {
+	TagName     string       `json:"tag_name"`
+	PublishedAt time.Time    `json:"published_at"`
+	Draft       bool         `json:"draft"`
+	Prerelease  bool         `json:"prerelease"`
+	Assets      []giteaAsset `json:"assets"`
+}
+
+// NewGiteaUpdateChecker will return an update checker that reads releases
+// from the owner/repo passed in on host. host is the base url of the
+// Gitea or Forgejo instance, and defaults to codeberg.org when empty
+func NewGiteaUpdateChecker(host string, fqRepo string, opts ...Option) updatechecker.UpdateChecker {
+	if host == "" {
+		host = CodebergHost
+	}
+
+	repoParts := strings.Split(strings.Trim(fqRepo, "/"), "/")
+	if len(repoParts) != 2 {
+		panic(fmt.Sprintf("invalid repo: %s", fqRepo))
+	}
+
+	c := GiteaUpdateChecker{
+		host:        strings.TrimRight(host, "/"),
+		timeout:     time.Second * 3, // a default
+		httpClient:  http.DefaultClient,
+		retryPolicy: retry.DefaultPolicy,
+		parsedRepo: struct {
+			owner string
+			repo  string
+		}{
+			owner: repoParts[0],
+			repo:  repoParts[1],
+		},
+	}
+
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	c.httpClient = retry.NewClient(c.httpClient, c.retryPolicy)
+
+	return c
+}
+
+// DownloadVersion will download and extract the specific version, returning
+// a path to the extracted file in the archive
+// it's the responsibility of the caller to clean up the extracted file
+func (c GiteaUpdateChecker) DownloadVersion(version string, requireChecksumMatch bool) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	return c.DownloadVersionContext(ctx, version, requireChecksumMatch)
+}
+
+// DownloadVersionContext is DownloadVersion, stopping when ctx is done
+func (c GiteaUpdateChecker) DownloadVersionContext(ctx context.Context, version string, requireChecksumMatch bool) (string, error) {
+	releaseInfo, err := getReleaseDetails(ctx, c.httpClient, c.host, c.parsedRepo.owner, c.parsedRepo.repo, version)
+	if err != nil {
+		return "", errors.Wrap(err, "get release details")
+	}
+
+	assets := toReleaseAssets(releaseInfo.Assets)
+
+	asset, err := release.BestAsset(assets, runtime.GOOS, runtime.GOARCH)
+	if err != nil {
+		return "", errors.Wrap(err, "best asset")
+	}
+
+	checksumAsset := release.ChecksumAsset(assets, asset.Name)
+
+	fileInArchivePath, err := release.DownloadAndVerify(ctx, c.httpClient, *asset, checksumAsset)
+	if err != nil {
+		return "", errors.Wrap(err, "download and verify")
+	}
+
+	return fileInArchivePath, nil
+}
+
+// GetLatestVersion will return the latest version information from the repository
+func (c GiteaUpdateChecker) GetLatestVersion(timeout time.Duration) (*updatechecker.VersionInfo, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return c.GetLatestVersionContext(ctx)
+}
+
+// GetLatestVersionContext is GetLatestVersion, stopping when ctx is done
+func (c GiteaUpdateChecker) GetLatestVersionContext(ctx context.Context) (*updatechecker.VersionInfo, error) {
+	// the latest release never includes prereleases, and it can be
+	// outside the constraint
+	if !updatechecker.IsStableChannel(c.channel) || c.versionConstraint != nil {
+		return c.getLatestVersionInChannel(ctx)
+	}
+
+	latestReleaseInfo, err := getReleaseDetails(ctx, c.httpClient, c.host, c.parsedRepo.owner, c.parsedRepo.repo, "latest")
+	if err != nil {
+		return nil, errors.Wrap(err, "get release details")
+	}
+
+	latestVersion := &updatechecker.VersionInfo{
+		Version:    latestReleaseInfo.TagName,
+		ReleasedAt: &latestReleaseInfo.PublishedAt,
+	}
+
+	return latestVersion, nil
+}
+
+// getLatestVersionInChannel will return the highest version in the channel
+// that satisfies the constraint, reading every page of releases so that
+// a constraint on an older line still finds it
+func (c GiteaUpdateChecker) getLatestVersionInChannel(ctx context.Context) (*updatechecker.VersionInfo, error) {
+	versions, err := c.ListVersionsContext(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "list versions")
+	}
+
+	if len(versions) == 0 {
+		return nil, ErrReleaseNotFound
+	}
+
+	// versions are sorted by semver, oldest first
+	latestVersion := versions[len(versions)-1]
+	return &latestVersion, nil
+}
+
+// ListVersions will return every release in the channel that satisfies
+// the constraint, reading every page of releases
+func (c GiteaUpdateChecker) ListVersions(timeout time.Duration) ([]updatechecker.VersionInfo, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return c.ListVersionsContext(ctx)
+}
+
+// ListVersionsContext is ListVersions, stopping when ctx is done
+func (c GiteaUpdateChecker) ListVersionsContext(ctx context.Context) ([]updatechecker.VersionInfo, error) {
+	versions := []updatechecker.VersionInfo{}
+
+	uri := releasesURI(c.host, c.parsedRepo.owner, c.parsedRepo.repo)
+	for uri != "" {
+		releases := []giteaReleaseInfo{}
+		nextURI, err := getJSONPage(ctx, c.httpClient, uri, &releases)
+		if err != nil {
+			return nil, errors.Wrap(err, "list releases")
+		}
+
+		for _, releaseInfo := range releases {
+			if !c.offers(releaseInfo) {
+				continue
+			}
+
+			publishedAt := releaseInfo.PublishedAt
+			versions = append(versions, updatechecker.VersionInfo{
+				Version:    releaseInfo.TagName,
+				ReleasedAt: &publishedAt,
+			})
+		}
+
+		uri = nextURI
+	}
+
+	updatechecker.SortVersions(versions)
+	return versions, nil
+}
+
+// offers will return true when the release is in the channel, satisfies
+// the constraint and isn't a draft
+func (c GiteaUpdateChecker) offers(releaseInfo giteaReleaseInfo) bool {
+	if releaseInfo.Draft || !updatechecker.InChannel(releaseInfo.TagName, releaseInfo.Prerelease, c.channel) {
+		return false
+	}
+
+	return updatechecker.SatisfiesConstraint(releaseInfo.TagName, c.versionConstraint)
+}
+
+func toReleaseAssets(giteaAssets []giteaAsset) []release.Asset {
+	assets := []release.Asset{}
+	for _, asset := range giteaAssets {
+		assets = append(assets, release.Asset{
+			Name: asset.Name,
+			URL:  asset.BrowserDownloadURL,
+		})
+	}
+
+	return assets
+}
+
+func getReleaseDetails(ctx context.Context, httpClient *http.Client, host string, owner string, repo string, releaseName string) (*giteaReleaseInfo, error) {
+	uri := ""
+
+	// the latest endpoint excludes drafts and prereleases
+	if releaseName == "latest" {
+		uri = fmt.Sprintf("%s/api/v1/repos/%s/%s/releases/latest", host, owner, repo)
+	} else {
+		uri = fmt.Sprintf("%s/api/v1/repos/%s/%s/releases/tags/%s", host, owner, repo, url.PathEscape(releaseName))
+	}
+
+	releaseInfo := giteaReleaseInfo{}
+	if err := getJSON(ctx, httpClient, uri, &releaseInfo); err != nil {
+		return nil, err
+	}
+
+	return &releaseInfo, nil
+}
+
+// releasesURI is the first page of releases
+func releasesURI(host string, owner string, repo string) string {
+	return fmt.Sprintf("%s/api/v1/repos/%s/%s/releases?limit=50", host, owner, repo)
+}
+
+func getJSON(ctx context.Context, httpClient *http.Client, uri string, v interface{}) error {
+	_, err := getJSONPage(ctx, httpClient, uri, v)
+	return err
+}
+
+// getJSONPage is getJSON, also returning the uri of the next page of a
+// paginated response, or "" when it's the last page
+func getJSONPage(ctx context.Context, httpClient *http.Client, uri string, v interface{}) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", uri, nil)
+	if err != nil {
+		return "", errors.Wrap(err, "new request")
+	}
+
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		if release.TimeoutError(err) == ErrTimeoutExceeded {
+			return "", ErrTimeoutExceeded
+		}
+		return "", errors.Wrap(err, "do request")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		if resp.StatusCode == http.StatusNotFound {
+			return "", ErrReleaseNotFound
+		}
+
+		return "", errors.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return "", errors.Wrap(err, "decode response")
+	}
+
+	return release.NextPageURL(resp.Header.Get("Link")), nil
+}
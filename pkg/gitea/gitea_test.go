@@ -0,0 +1,171 @@
+package gitea
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/Masterminds/semver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/usrbinapp/usrbin-go/internal/archivetest"
+	"github.com/usrbinapp/usrbin-go/pkg/release"
+)
+
+func Test_GetLatestVersion(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		body        string
+		wantVersion string
+		wantErr     error
+	}{
+		{
+			name:        "latest release",
+			status:      http.StatusOK,
+			body:        `{"tag_name": "v1.2.0", "published_at": "2023-01-01T00:00:00Z"}`,
+			wantVersion: "v1.2.0",
+		},
+		{
+			name:    "no releases",
+			status:  http.StatusNotFound,
+			body:    `{"message": "release not found"}`,
+			wantErr: ErrReleaseNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := require.New(t)
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/api/v1/repos/owner/cli/releases/latest", r.URL.Path)
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			}))
+			defer server.Close()
+
+			c := NewGiteaUpdateChecker(server.URL, "owner/cli")
+			got, err := c.GetLatestVersion(time.Second)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			req.NoError(err)
+			assert.Equal(t, tt.wantVersion, got.Version)
+			assert.Equal(t, time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), got.ReleasedAt.UTC())
+		})
+	}
+}
+
+func Test_GetLatestVersionConstraint(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/repos/owner/cli/releases", r.URL.Path)
+
+		if r.URL.Query().Get("page") == "2" {
+			fmt.Fprint(w, `[
+				{"tag_name": "v1.9.0", "published_at": "2023-01-01T00:00:00Z"},
+				{"tag_name": "v1.8.0", "published_at": "2022-12-01T00:00:00Z"}
+			]`)
+			return
+		}
+
+		w.Header().Set("Link", fmt.Sprintf(`<%s/api/v1/repos/owner/cli/releases?limit=50&page=2>; rel="next"`, server.URL))
+		fmt.Fprint(w, `[
+			{"tag_name": "v2.1.0", "published_at": "2023-03-01T00:00:00Z"},
+			{"tag_name": "v2.0.0", "published_at": "2023-02-01T00:00:00Z"}
+		]`)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		constraint  string
+		wantVersion string
+	}{
+		{constraint: "<3.0", wantVersion: "v2.1.0"},
+		{constraint: "<2.0", wantVersion: "v1.9.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.constraint, func(t *testing.T) {
+			constraint, err := semver.NewConstraint(tt.constraint)
+			require.NoError(t, err)
+
+			c := NewGiteaUpdateChecker(server.URL, "owner/cli", WithVersionConstraint(constraint))
+			got, err := c.GetLatestVersion(time.Second)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantVersion, got.Version)
+		})
+	}
+}
+
+func Test_DownloadVersion(t *testing.T) {
+	req := require.New(t)
+
+	archiveContents, archiveChecksum, err := archivetest.CreateTGZWithExecutable([]byte("new version"))
+	req.NoError(err)
+
+	assetName := fmt.Sprintf("cli_%s_%s.tar.gz", runtime.GOOS, runtime.GOARCH)
+
+	tests := []struct {
+		name     string
+		checksum string
+		wantErr  error
+	}{
+		{
+			name:     "checksum matches",
+			checksum: archiveChecksum,
+		},
+		{
+			name:     "checksum mismatch",
+			checksum: "0000000000000000000000000000000000000000000000000000000000000000",
+			wantErr:  release.ErrChecksumMismatch,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := require.New(t)
+
+			mux := http.NewServeMux()
+			server := httptest.NewServer(mux)
+			defer server.Close()
+
+			mux.HandleFunc("/api/v1/repos/owner/cli/releases/tags/v1.0.0", func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintf(w, `{
+					"tag_name": "v1.0.0",
+					"published_at": "2023-01-01T00:00:00Z",
+					"assets": [
+						{"name": "cli_other_other.tar.gz", "browser_download_url": "%[1]s/other"},
+						{"name": "%[2]s", "browser_download_url": "%[1]s/archive"},
+						{"name": "%[2]s.sha256", "browser_download_url": "%[1]s/archive.sha256"}
+					]
+				}`, server.URL, assetName)
+			})
+			mux.HandleFunc("/archive", func(w http.ResponseWriter, r *http.Request) {
+				w.Write(archiveContents)
+			})
+			mux.HandleFunc("/archive.sha256", func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintf(w, "%s  %s\n", tt.checksum, assetName)
+			})
+
+			c := NewGiteaUpdateChecker(server.URL, "owner/cli")
+			got, err := c.DownloadVersion("v1.0.0", true)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			req.NoError(err)
+			defer os.Remove(got)
+
+			contents, err := ioutil.ReadFile(got)
+			req.NoError(err)
+			assert.Equal(t, "new version", string(contents))
+		})
+	}
+}
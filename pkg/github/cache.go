@@ -0,0 +1,91 @@
+package github
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// cachedResponse is a release response stored in the cache dir, with the
+// validators that GitHub sent for it, and the Link header of a paginated
+// response
+type cachedResponse struct {
+	ETag         string          `json:"etag,omitempty"`
+	LastModified string          `json:"lastModified,omitempty"`
+	Link         string          `json:"link,omitempty"`
+	Body         json.RawMessage `json:"body"`
+}
+
+// cachePath will return the file in cacheDir for the response to uri
+func cachePath(cacheDir string, uri string) string {
+	sum := sha256.Sum256([]byte(uri))
+	return filepath.Join(cacheDir, hex.EncodeToString(sum[:])+".json")
+}
+
+// readCachedResponse will return the cached response to uri, or nil when
+// there isn't one that can be used
+func readCachedResponse(cacheDir string, uri string) *cachedResponse {
+	if cacheDir == "" {
+		return nil
+	}
+
+	b, err := ioutil.ReadFile(cachePath(cacheDir, uri))
+	if err != nil {
+		return nil
+	}
+
+	cached := cachedResponse{}
+	if err := json.Unmarshal(b, &cached); err != nil {
+		return nil
+	}
+
+	if cached.ETag == "" && cached.LastModified == "" {
+		return nil
+	}
+
+	return &cached
+}
+
+// writeCachedResponse will store the response to uri, when it can be
+// validated later
+func writeCachedResponse(cacheDir string, uri string, cached cachedResponse) error {
+	if cacheDir == "" || (cached.ETag == "" && cached.LastModified == "") {
+		return nil
+	}
+
+	if err := os.MkdirAll(cacheDir, 0700); err != nil {
+		return errors.Wrap(err, "create cache dir")
+	}
+
+	b, err := json.Marshal(cached)
+	if err != nil {
+		return errors.Wrap(err, "marshal cached response")
+	}
+
+	// write to a temp file and rename, so that another process
+	// never reads a partial file
+	tmpFile, err := ioutil.TempFile(cacheDir, "response")
+	if err != nil {
+		return errors.Wrap(err, "create temp file")
+	}
+	defer os.Remove(tmpFile.Name())
+
+	if _, err := tmpFile.Write(b); err != nil {
+		tmpFile.Close()
+		return errors.Wrap(err, "write temp file")
+	}
+	if err := tmpFile.Close(); err != nil {
+		return errors.Wrap(err, "close temp file")
+	}
+
+	if err := os.Rename(tmpFile.Name(), cachePath(cacheDir, uri)); err != nil {
+		return errors.Wrap(err, "rename temp file")
+	}
+
+	return nil
+}
@@ -1,33 +1,32 @@
 package github
 
 import (
-	"archive/tar"
-	"bufio"
-	"compress/gzip"
-	"crypto/sha256"
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"io/ioutil"
 	"net/http"
 	"os"
-	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/Masterminds/semver"
 	"github.com/pkg/errors"
-	"github.com/usrbinapp/usrbin-go/pkg/logger"
+	"github.com/usrbinapp/usrbin-go/pkg/archive"
+	"github.com/usrbinapp/usrbin-go/pkg/release"
+	"github.com/usrbinapp/usrbin-go/pkg/retry"
 	"github.com/usrbinapp/usrbin-go/pkg/updatechecker"
 )
 
 var (
-	ErrUnknownArchiveType        = errors.New("unknown archive type")
-	ErrNoMatchingArchitectures   = errors.New("no matching architectures")
-	ErrNoAssets                  = errors.New("no assets")
-	ErrChecksumMismatch          = errors.New("checksum mismatch")
-	ErrUnsupportedChecksumFormat = errors.New("unsupported checksum format")
-	ErrTimeoutExceeded           = errors.New("timeout exceeded")
+	ErrUnknownArchiveType        = archive.ErrUnknownArchiveType
+	ErrNoMatchingArchitectures   = release.ErrNoMatchingArchitectures
+	ErrNoAssets                  = release.ErrNoAssets
+	ErrChecksumMismatch          = release.ErrChecksumMismatch
+	ErrUnsupportedChecksumFormat = release.ErrUnsupportedChecksumFormat
+	ErrTimeoutExceeded           = release.ErrTimeoutExceeded
 )
 
 type GitHubUpdateChecker struct {
@@ -37,6 +36,16 @@ type GitHubUpdateChecker struct {
 
 	host string
 
+	token string
+
+	httpClient  *http.Client
+	retryPolicy retry.Policy
+
+	cacheDir string
+
+	channel           string
+	versionConstraint *semver.Constraints
+
 	parsedRepo struct {
 		owner string
 		repo  string
@@ -44,8 +53,11 @@ type GitHubUpdateChecker struct {
 }
 
 var _ updatechecker.UpdateChecker = (*GitHubUpdateChecker)(nil)
+var _ updatechecker.ContextUpdateChecker = (*GitHubUpdateChecker)(nil)
+var _ updatechecker.VersionLister = (*GitHubUpdateChecker)(nil)
 
 type githubAsset struct {
+	URL                string `json:"url"`
 	Name               string `json:"name"`
 	ContentType        string `json:"content_type"`
 	State              string `json:"state"`
@@ -56,6 +68,8 @@ type githubAsset struct {
 type gitHubReleaseInfo struct {
 	TagName     string        `json:"tag_name"`
 	PublishedAt time.Time     `json:"published_at"`
+	Draft       bool          `json:"draft"`
+	Prerelease  bool          `json:"prerelease"`
 	Assets      []githubAsset `json:"assets"`
 }
 
@@ -63,24 +77,114 @@ var (
 	ErrReleaseNotFound = errors.New("release not found")
 )
 
-func NewGitHubUpdateChecker(fqRepo string) updatechecker.UpdateChecker {
-	host := "https://api.github.com"
+// RateLimitedError is returned when GitHub refuses a request because
+// the rate limit has been used up
+type RateLimitedError = updatechecker.RateLimitedError
+
+// Option is a functional option for configuring the update checker
+type Option func(*GitHubUpdateChecker)
+
+// WithToken will authenticate all requests with the token passed in, which
+// is required for private repositories. when no token is set, the token is
+// read from GH_TOKEN or GITHUB_TOKEN. GitHub Enterprise Server reads
+// GH_ENTERPRISE_TOKEN or GITHUB_ENTERPRISE_TOKEN first, then falls back
+// to GH_TOKEN or GITHUB_TOKEN
+func WithToken(token string) Option {
+	return func(c *GitHubUpdateChecker) {
+		c.token = token
+	}
+}
+
+// WithHTTPClient will make all requests, including downloads, with the
+// http client passed in instead of http.DefaultClient
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *GitHubUpdateChecker) {
+		if httpClient != nil {
+			c.httpClient = httpClient
+		}
+	}
+}
+
+// WithRetryPolicy will set how failed requests are retried, instead of
+// retry.DefaultPolicy
+func WithRetryPolicy(policy retry.Policy) Option {
+	return func(c *GitHubUpdateChecker) {
+		c.retryPolicy = policy
+	}
+}
+
+// WithCacheDir will store release responses in dir, along with their
+// ETag and Last-Modified, and make later requests for the same release
+// conditional. GitHub doesn't count a 304 against the rate limit
+func WithCacheDir(dir string) Option {
+	return func(c *GitHubUpdateChecker) {
+		c.cacheDir = dir
+	}
+}
+
+// WithChannel will offer the releases in the channel (stable, rc, beta,
+// alpha, nightly or any other name). see updatechecker.InChannel. releases
+// that GitHub flags as prereleases are never on the stable channel
+func WithChannel(channel string) Option {
+	return func(c *GitHubUpdateChecker) {
+		c.channel = channel
+	}
+}
+
+// WithVersionConstraint will only offer the releases that satisfy
+// constraint. see updatechecker.SatisfiesConstraint
+func WithVersionConstraint(constraint *semver.Constraints) Option {
+	return func(c *GitHubUpdateChecker) {
+		c.versionConstraint = constraint
+	}
+}
+
+const (
+	DefaultAPIHost = "https://api.github.com"
+)
+
+// NewGitHubUpdateChecker will return an update checker for the repo passed in.
+// the repo can be "owner/repo" for github.com, or "host/owner/repo" for
+// a GitHub Enterprise Server instance at host
+func NewGitHubUpdateChecker(fqRepo string, opts ...Option) updatechecker.UpdateChecker {
+	host := DefaultAPIHost
 	owner, repo := "", ""
 	repoParts := strings.Split(fqRepo, "/")
 	if len(repoParts) == 2 {
 		owner = repoParts[0]
 		repo = repoParts[1]
 	} else if len(repoParts) == 3 {
+		host = apiHostForHost(repoParts[0])
 		owner = repoParts[1]
 		repo = repoParts[2]
 	} else {
 		panic(fmt.Sprintf("invalid repo: %s", fqRepo))
 	}
 
-	return GitHubUpdateChecker{
-		repo:    repo,
-		host:    host,
-		timeout: time.Second * 3, // a default
+	return newGitHubUpdateChecker(host, owner, repo, opts)
+}
+
+// NewGitHubEnterpriseUpdateChecker will return an update checker for the
+// owner/repo passed in on the GitHub Enterprise Server at baseURL
+// (https://github.example.com). the /api/v3 path is added to baseURL
+// when it's not already there
+func NewGitHubEnterpriseUpdateChecker(baseURL string, fqRepo string, opts ...Option) updatechecker.UpdateChecker {
+	repoParts := strings.Split(strings.Trim(fqRepo, "/"), "/")
+	if len(repoParts) != 2 {
+		panic(fmt.Sprintf("invalid repo: %s", fqRepo))
+	}
+
+	return newGitHubUpdateChecker(enterpriseAPIHost(baseURL), repoParts[0], repoParts[1], opts)
+}
+
+func newGitHubUpdateChecker(host string, owner string, repo string, opts []Option) GitHubUpdateChecker {
+	c := GitHubUpdateChecker{
+		repo:        repo,
+		host:        host,
+		token:       tokenFromEnv(host),
+		timeout:     time.Second * 3, // a default
+		httpClient:  http.DefaultClient,
+		retryPolicy: retry.DefaultPolicy,
 		parsedRepo: struct {
 			owner string
 			repo  string
@@ -89,13 +193,74 @@ func NewGitHubUpdateChecker(fqRepo string) updatechecker.UpdateChecker {
 			repo:  repo,
 		},
 	}
+
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	c.httpClient = retry.NewClient(c.httpClient, c.retryPolicy)
+
+	return c
+}
+
+// tokenFromEnv will return the token for host from the environment, using
+// the same variables as the gh cli. an enterprise host falls back to
+// GH_TOKEN and GITHUB_TOKEN, since it's only used when the app was
+// configured with it, and that's the token that a GitHub Enterprise
+// Server actions runner sets
+func tokenFromEnv(host string) string {
+	envVars := []string{"GH_TOKEN", "GITHUB_TOKEN"}
+	if host != DefaultAPIHost {
+		envVars = append([]string{"GH_ENTERPRISE_TOKEN", "GITHUB_ENTERPRISE_TOKEN"}, envVars...)
+	}
+
+	for _, envVar := range envVars {
+		if token := os.Getenv(envVar); token != "" {
+			return token
+		}
+	}
+
+	return ""
+}
+
+// apiHostForHost will return the api url for a host name, as found
+// in a "host/owner/repo" string
+func apiHostForHost(host string) string {
+	if host == "github.com" || host == "api.github.com" {
+		return DefaultAPIHost
+	}
+
+	return enterpriseAPIHost(fmt.Sprintf("https://%s", host))
+}
+
+// enterpriseAPIHost will return the rest api url for the GitHub Enterprise
+// Server at baseURL
+func enterpriseAPIHost(baseURL string) string {
+	baseURL = strings.TrimRight(baseURL, "/")
+	if !strings.Contains(baseURL, "://") {
+		baseURL = fmt.Sprintf("https://%s", baseURL)
+	}
+
+	if strings.HasSuffix(baseURL, "/api/v3") {
+		return baseURL
+	}
+
+	return fmt.Sprintf("%s/api/v3", baseURL)
 }
 
 // DownloadVersion will download and extract the specific version, returning
 // a path to the extracted file in the archive
 // it's the responsibility of the caller to clean up the extracted file
 func (c GitHubUpdateChecker) DownloadVersion(version string, requireChecksumMatch bool) (string, error) {
-	releaseInfo, err := getReleaseDetails(c.timeout, c.host, c.parsedRepo.owner, c.parsedRepo.repo, version)
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	return c.DownloadVersionContext(ctx, version, requireChecksumMatch)
+}
+
+// DownloadVersionContext is DownloadVersion, stopping when ctx is done
+func (c GitHubUpdateChecker) DownloadVersionContext(ctx context.Context, version string, requireChecksumMatch bool) (string, error) {
+	releaseInfo, err := c.getReleaseDetails(ctx, version)
 	if err != nil {
 		return "", errors.Wrap(err, "get release details")
 	}
@@ -105,31 +270,20 @@ func (c GitHubUpdateChecker) DownloadVersion(version string, requireChecksumMatc
 		return "", errors.Wrap(err, "best asset")
 	}
 
-	archivePath, fileInArchivePath, err := downloadFile(asset.BrowserDownloadURL, c.timeout)
-	if err != nil {
-		return "", errors.Wrap(err, "download file")
-	}
-	defer os.Remove(archivePath)
-
 	checksumAsset, err := checksum(releaseInfo.Assets, asset.Name)
 	if err != nil {
 		return "", errors.Wrap(err, "checksum")
 	}
 
+	var checksumReleaseAsset *release.Asset
 	if checksumAsset != nil {
-		desiredChecksum, err := downloadAndParseChecksum(c.timeout, checksumAsset.BrowserDownloadURL, asset.Name)
-		if err != nil {
-			return "", errors.Wrap(err, "download and parse checksum")
-		}
-
-		actualChecksum, err := checksumFile(archivePath)
-		if err != nil {
-			return "", errors.Wrap(err, "checksum file")
-		}
+		a := c.releaseAsset(*checksumAsset)
+		checksumReleaseAsset = &a
+	}
 
-		if actualChecksum != desiredChecksum {
-			return "", ErrChecksumMismatch
-		}
+	fileInArchivePath, err := release.DownloadAndVerify(ctx, c.httpClient, c.releaseAsset(*asset), checksumReleaseAsset)
+	if err != nil {
+		return "", errors.Wrap(err, "download and verify")
 	}
 
 	return fileInArchivePath, nil
@@ -137,14 +291,23 @@ func (c GitHubUpdateChecker) DownloadVersion(version string, requireChecksumMatc
 
 // GetLatestVersion will return the latest version information from the git repository
 func (c GitHubUpdateChecker) GetLatestVersion(timeout time.Duration) (*updatechecker.VersionInfo, error) {
-	c.timeout = timeout
-	latestReleaseInfo, err := getReleaseDetails(c.timeout, c.host, c.parsedRepo.owner, c.parsedRepo.repo, "latest")
-	if err != nil {
-		return nil, errors.Wrap(err, "get release details")
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return c.GetLatestVersionContext(ctx)
+}
+
+// GetLatestVersionContext is GetLatestVersion, stopping when ctx is done
+func (c GitHubUpdateChecker) GetLatestVersionContext(ctx context.Context) (*updatechecker.VersionInfo, error) {
+	// the latest release never includes prereleases, and it can be
+	// outside the constraint
+	if !updatechecker.IsStableChannel(c.channel) || c.versionConstraint != nil {
+		return c.getLatestVersionInChannel(ctx)
 	}
 
-	if latestReleaseInfo == nil {
-		return nil, nil
+	latestReleaseInfo, err := c.getReleaseDetails(ctx, "latest")
+	if err != nil {
+		return nil, errors.Wrap(err, "get release details")
 	}
 
 	latestVersion := &updatechecker.VersionInfo{
@@ -155,296 +318,290 @@ func (c GitHubUpdateChecker) GetLatestVersion(timeout time.Duration) (*updateche
 	return latestVersion, nil
 }
 
-func downloadAndParseChecksum(timeout time.Duration, url string, assetName string) (string, error) {
-	// download the file
-	httpClient := http.Client{
-		Timeout: timeout,
-	}
-	resp, err := httpClient.Get(url)
+// getLatestVersionInChannel will return the highest version in the channel
+// that satisfies the constraint, reading every page of releases so that
+// a constraint on an older line still finds it
+func (c GitHubUpdateChecker) getLatestVersionInChannel(ctx context.Context) (*updatechecker.VersionInfo, error) {
+	versions, err := c.ListVersionsContext(ctx)
 	if err != nil {
-		if os.IsTimeout(err) {
-			return "", ErrTimeoutExceeded
-		}
-		return "", err
+		return nil, errors.Wrap(err, "list versions")
 	}
-	defer resp.Body.Close()
 
-	// parse the file
-	// supported formats are sha256[whitespace]filepath per line
-
-	// first try to find the exact match
-	scanner := bufio.NewScanner(resp.Body)
-	for scanner.Scan() {
-		line := scanner.Text()
-		parts := strings.Fields(line)
-		if len(parts) == 2 {
-			if strings.HasSuffix(strings.TrimSpace(parts[1]), assetName) {
-				return strings.TrimSpace(parts[0]), nil
-			}
-		}
+	if len(versions) == 0 {
+		return nil, ErrReleaseNotFound
 	}
 
-	return "", ErrUnsupportedChecksumFormat
+	// versions are sorted by semver, oldest first
+	latestVersion := versions[len(versions)-1]
+	return &latestVersion, nil
 }
 
-func checksumFile(path string) (string, error) {
-	f, err := os.Open(path)
-	if err != nil {
-		return "", err
-	}
-	defer f.Close()
+// ListVersions will return every release in the channel that satisfies
+// the constraint, reading every page of releases
+func (c GitHubUpdateChecker) ListVersions(timeout time.Duration) ([]updatechecker.VersionInfo, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
 
-	h := sha256.New()
-	if _, err := io.Copy(h, f); err != nil {
-		return "", err
-	}
-
-	return fmt.Sprintf("%x", h.Sum(nil)), nil
+	return c.ListVersionsContext(ctx)
 }
 
-// checksumAsset will search through the assets and attempt to find the
-// download url for the sha256 checksum for the asset provided
-// this works by looking for the asset name with the checksum appended to it
-// it will return empty string and no error if there is not checksum
-func checksum(assets []githubAsset, assetName string) (*githubAsset, error) {
-	for _, asset := range assets {
-		if asset.State != "uploaded" {
-			continue
+// ListVersionsContext is ListVersions, stopping when ctx is done
+func (c GitHubUpdateChecker) ListVersionsContext(ctx context.Context) ([]updatechecker.VersionInfo, error) {
+	versions := []updatechecker.VersionInfo{}
+
+	uri := c.releasesURI()
+	for uri != "" {
+		releases := []gitHubReleaseInfo{}
+		nextURI, err := c.getJSONPage(ctx, uri, &releases)
+		if err != nil {
+			return nil, errors.Wrap(err, "list releases")
 		}
 
-		if strings.HasPrefix(asset.Name, assetName) {
-			if strings.HasSuffix(asset.Name, ".sha256") {
-				return &asset, nil
+		for _, releaseInfo := range releases {
+			if !c.offers(releaseInfo) {
+				continue
 			}
+
+			publishedAt := releaseInfo.PublishedAt
+			versions = append(versions, updatechecker.VersionInfo{
+				Version:    releaseInfo.TagName,
+				ReleasedAt: &publishedAt,
+			})
 		}
+
+		uri = nextURI
 	}
 
-	// no exact match, look for a common checksums file
-	for _, asset := range assets {
-		if asset.State != "uploaded" {
-			continue
-		}
+	updatechecker.SortVersions(versions)
+	return versions, nil
+}
 
-		if strings.Contains(asset.Name, "checksums") {
-			if strings.HasSuffix(asset.Name, ".txt") {
-				return &asset, nil
-			}
-		}
+// offers will return true when the release is in the channel, satisfies
+// the constraint and isn't a draft
+func (c GitHubUpdateChecker) offers(releaseInfo gitHubReleaseInfo) bool {
+	if releaseInfo.Draft || !updatechecker.InChannel(releaseInfo.TagName, releaseInfo.Prerelease, c.channel) {
+		return false
 	}
 
-	return nil, nil
+	return updatechecker.SatisfiesConstraint(releaseInfo.TagName, c.versionConstraint)
+}
+
+// checksum will search through the assets and attempt to find the
+// sha256 checksum for the asset provided, using the same rules as
+// release.ChecksumAsset. only assets that have finished uploading are
+// considered
+// it will return nil and no error if there is not checksum
+func checksum(assets []githubAsset, assetName string) (*githubAsset, error) {
+	checksumAsset := release.ChecksumAsset(toReleaseAssets(assets), assetName)
+	if checksumAsset == nil {
+		return nil, nil
+	}
+
+	return findAsset(assets, checksumAsset.Name), nil
 }
 
 // bestAsset will search through the assets, find the best (most appropriate)
-// asset for the os nad arch provided. this will that asset
-// for the asset
+// asset for the os and arch provided and return that asset. only assets that
+// have finished uploading are considered
 func bestAsset(assets []githubAsset, goos string, goarch string) (*githubAsset, error) {
 	if len(assets) == 0 {
 		return nil, ErrNoAssets
 	}
 
-	// find the most appropriate asset
-	for _, asset := range assets {
-		if asset.State != "uploaded" || asset.ContentType == "application/octet-stream" {
-			continue
-		}
-
-		lowercaseName := strings.ToLower(asset.Name)
-		if strings.Contains(lowercaseName, goos) {
-			if strings.Contains(lowercaseName, goarch) {
-				return &asset, nil
-			}
-		}
+	best, err := release.BestAsset(toReleaseAssets(assets), goos, goarch)
+	if err != nil {
+		return nil, err
 	}
 
-	// we didn't find a specific match, look for the os with "all" for the arch
+	return findAsset(assets, best.Name), nil
+}
+
+func toReleaseAssets(assets []githubAsset) []release.Asset {
+	releaseAssets := []release.Asset{}
 	for _, asset := range assets {
 		if asset.State != "uploaded" {
 			continue
 		}
 
-		lowercaseName := strings.ToLower(asset.Name)
-		if strings.Contains(lowercaseName, runtime.GOOS) {
-			if strings.Contains(lowercaseName, "all") {
-				return &asset, nil
-			}
-		}
+		releaseAssets = append(releaseAssets, release.Asset{
+			Name:        asset.Name,
+			URL:         asset.BrowserDownloadURL,
+			ContentType: asset.ContentType,
+		})
 	}
 
-	return nil, ErrNoMatchingArchitectures
+	return releaseAssets
 }
 
-// downloadFile will return two strings:
-//   - the path to the downloaded file (the archive)
-//   - the path to the file that is probably the binary
-func downloadFile(url string, timeout time.Duration) (string, string, error) {
-	tmpFile, err := ioutil.TempFile("", "usrbin")
-	if err != nil {
-		return "", "", errors.Wrap(err, "create temp file")
-	}
-
-	httpClient := http.Client{
-		Timeout: timeout,
-	}
-	resp, err := httpClient.Get(url)
-	if err != nil {
-		if os.IsTimeout(err) {
-			return "", "", ErrTimeoutExceeded
+// releaseAsset will return the asset to download. browser_download_url
+// doesn't accept a token, so when there is one the asset is downloaded
+// through the api instead
+func (c GitHubUpdateChecker) releaseAsset(asset githubAsset) release.Asset {
+	if c.token == "" || asset.URL == "" {
+		return release.Asset{
+			Name:        asset.Name,
+			URL:         asset.BrowserDownloadURL,
+			ContentType: asset.ContentType,
 		}
-		return "", "", errors.Wrap(err, "get file")
 	}
-	defer resp.Body.Close()
 
-	_, err = io.Copy(tmpFile, resp.Body)
-	if err != nil {
-		return "", "", errors.Wrap(err, "copy file")
+	header := http.Header{}
+	header.Set("Accept", "application/octet-stream")
+	header.Set("Authorization", fmt.Sprintf("Bearer %s", c.token))
+
+	return release.Asset{
+		Name:        asset.Name,
+		URL:         asset.URL,
+		ContentType: asset.ContentType,
+		Header:      header,
 	}
+}
 
-	probableFile, err := findProbableFileInWhatMightBeAnArchive(tmpFile.Name())
-	if err != nil {
-		return "", "", errors.Wrap(err, "find probable file")
+func findAsset(assets []githubAsset, name string) *githubAsset {
+	for _, asset := range assets {
+		if asset.Name == name {
+			return &asset
+		}
 	}
 
-	return tmpFile.Name(), probableFile, nil
+	return nil
 }
 
-func findProbableFileInWhatMightBeAnArchive(path string) (string, error) {
-	f, err := os.Open(path)
-	if err != nil {
-		return "", errors.Wrap(err, "open file")
-	}
+// getReleaseDetails will return the release, or the latest release when
+// releaseName is "latest"
+func (c GitHubUpdateChecker) getReleaseDetails(ctx context.Context, releaseName string) (*gitHubReleaseInfo, error) {
+	uri := ""
 
-	defer func() {
-		if err := f.Close(); err != nil {
-			logger.Error(err)
-		}
-	}()
+	if releaseName == "latest" {
+		uri = fmt.Sprintf("%s/repos/%s/%s/releases/latest", c.host, c.parsedRepo.owner, c.parsedRepo.repo)
+	} else {
+		uri = fmt.Sprintf("%s/repos/%s/%s/releases/tags/%s", c.host, c.parsedRepo.owner, c.parsedRepo.repo, releaseName)
+	}
 
-	// check if it's a gzip file
-	_, err = gzip.NewReader(f)
-	if err == nil {
-		return findProbableFileInGzip(path)
+	releaseInfo := gitHubReleaseInfo{}
+	if err := c.getJSON(ctx, uri, &releaseInfo); err != nil {
+		return nil, err
 	}
 
-	return "", ErrUnknownArchiveType
+	return &releaseInfo, nil
 }
 
-func findProbableFileInGzip(path string) (string, error) {
-	f, err := os.Open(path)
-	if err != nil {
-		return "", errors.Wrap(err, "open file")
-	}
+// releasesURI is the first page of releases
+func (c GitHubUpdateChecker) releasesURI() string {
+	return fmt.Sprintf("%s/repos/%s/%s/releases?per_page=100", c.host, c.parsedRepo.owner, c.parsedRepo.repo)
+}
 
-	defer func() {
-		if err := f.Close(); err != nil {
-			logger.Error(err)
-		}
-	}()
+// getJSON will decode the response from the api at uri into v. when there
+// is a cache dir, the request is conditional on the cached response having
+// changed, and a 304 (which doesn't count against the rate limit) decodes
+// the cached response
+func (c GitHubUpdateChecker) getJSON(ctx context.Context, uri string, v interface{}) error {
+	_, err := c.getJSONPage(ctx, uri, v)
+	return err
+}
 
-	gzr, err := gzip.NewReader(f)
+// getJSONPage is getJSON, also returning the uri of the next page of a
+// paginated response, or "" when it's the last page
+func (c GitHubUpdateChecker) getJSONPage(ctx context.Context, uri string, v interface{}) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", uri, nil)
 	if err != nil {
-		return "", errors.Wrap(err, "open gzip file")
+		return "", errors.Wrap(err, "new request")
 	}
 
-	tr := tar.NewReader(gzr)
-	for {
-		header, err := tr.Next()
-		if err == io.EOF {
-			break
-		}
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	if c.token != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.token))
+	}
 
-		if err != nil {
-			return "", errors.Wrap(err, "read next file")
+	cached := readCachedResponse(c.cacheDir, uri)
+	if cached != nil {
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
 		}
-
-		if header.Typeflag == tar.TypeReg {
-			// if the file is executable and matches the name of the current process
-			// then it's almost certainly the file we want
-
-			if isLikelyFile(header.Mode, header.Name, filepath.Base(os.Args[0])) {
-				tmpFile, err := ioutil.TempFile("", "usrbin")
-				if err != nil {
-					return "", errors.Wrap(err, "create temp file")
-				}
-
-				defer func() {
-					if err := tmpFile.Close(); err != nil {
-						logger.Error(err)
-					}
-				}()
-
-				if _, err := io.Copy(tmpFile, tr); err != nil {
-					return "", errors.Wrap(err, "copy file")
-				}
-
-				// set the mode on the file to match
-				if err := os.Chmod(tmpFile.Name(), os.FileMode(header.Mode)); err != nil {
-					return "", errors.Wrap(err, "set file mode")
-				}
-
-				return tmpFile.Name(), nil
-			}
+		if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
 		}
 	}
 
-	return "", errors.New("unable to find matching file in archive")
-}
-
-func isLikelyFile(mode int64, name string, currentExecutableName string) bool {
-	if mode&0111 != 0 {
-		if currentExecutableName == filepath.Base(name) {
-			return true
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		if release.TimeoutError(err) == ErrTimeoutExceeded {
+			return "", ErrTimeoutExceeded
 		}
+		return "", errors.Wrap(err, "do request")
 	}
+	defer resp.Body.Close()
 
-	return false
-}
+	var body []byte
+	var link string
+	switch {
+	case resp.StatusCode == http.StatusNotModified && cached != nil:
+		body = cached.Body
+		link = cached.Link
 
-func getReleaseDetails(timeout time.Duration, host string, owner string, repo string, releaseName string) (*gitHubReleaseInfo, error) {
-	uri := ""
+	case resp.StatusCode == http.StatusOK:
+		body, err = ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return "", errors.Wrap(err, "read response")
+		}
+		link = resp.Header.Get("Link")
+
+		// the cache is best effort, a response can always be fetched again
+		_ = writeCachedResponse(c.cacheDir, uri, cachedResponse{
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+			Link:         link,
+			Body:         body,
+		})
+
+	case resp.StatusCode == http.StatusNotFound:
+		return "", ErrReleaseNotFound
+
+	default:
+		if rateLimitedErr := rateLimitedError(resp, time.Now()); rateLimitedErr != nil {
+			return "", rateLimitedErr
+		}
 
-	if releaseName == "latest" {
-		uri = fmt.Sprintf("%s/repos/%s/%s/releases/latest", host, owner, repo)
-	} else {
-		uri = fmt.Sprintf("%s/repos/%s/%s/releases/tags/%s", host, owner, repo, releaseName)
+		return "", errors.Errorf("unexpected status code: %d", resp.StatusCode)
 	}
 
-	req, err := http.NewRequest("GET", uri, nil)
-	if err != nil {
-		return nil, errors.Wrap(err, "new request")
+	if err := json.Unmarshal(body, v); err != nil {
+		return "", errors.Wrap(err, "decode response")
 	}
 
-	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	return release.NextPageURL(link), nil
+}
 
-	httpClient := http.Client{
-		Timeout: timeout,
+// rateLimitedError will return a RateLimitedError when the response is
+// a 403 or 429 caused by the primary rate limit (X-RateLimit-Remaining
+// is 0) or a secondary rate limit (Retry-After is set), and nil otherwise
+func rateLimitedError(resp *http.Response, now time.Time) *RateLimitedError {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return nil
 	}
-	resp, err := httpClient.Do(req)
-	if err != nil {
-		if os.IsTimeout(err) {
-			return nil, ErrTimeoutExceeded
+
+	if retryAfter, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		remaining, _ := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining"))
+		return &RateLimitedError{
+			Remaining: remaining,
+			Reset:     now.Add(time.Duration(retryAfter) * time.Second),
 		}
-		return nil, errors.Wrap(err, "do request")
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		if resp.StatusCode == http.StatusNotFound {
-			return nil, ErrReleaseNotFound
-		}
+	if resp.Header.Get("X-RateLimit-Remaining") != "0" {
+		return nil
+	}
 
-		if resp.StatusCode == http.StatusForbidden && strings.Contains(resp.Header.Get("X-RateLimit-Remaining"), "0") {
-			return nil, nil // don't make the caller handle this
+	reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		// github always sends the reset, but assume the rate limit
+		// is per hour if it's missing
+		return &RateLimitedError{
+			Reset: now.Add(time.Hour),
 		}
-
-		return nil, errors.Errorf("unexpected status code: %d", resp.StatusCode)
 	}
 
-	releaseInfo := gitHubReleaseInfo{}
-
-	if err := json.NewDecoder(resp.Body).Decode(&releaseInfo); err != nil {
-		return nil, errors.Wrap(err, "decode response")
+	return &RateLimitedError{
+		Reset: time.Unix(reset, 0),
 	}
-
-	return &releaseInfo, nil
 }
@@ -1,87 +1,24 @@
 package github
 
 import (
+	"context"
+	"fmt"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"runtime"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/Masterminds/semver"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/usrbinapp/usrbin-go/internal/archivetest"
+	"github.com/usrbinapp/usrbin-go/pkg/updatechecker"
 )
 
-func Test_findProbableFileInWhatMightBeAnArchive(t *testing.T) {
-	tests := []struct {
-		name    string
-		content string
-		want    string
-		wantErr error
-	}{
-		{
-			name:    "not an archive file",
-			content: "",
-			want:    "",
-			wantErr: ErrUnknownArchiveType,
-		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			tmp, err := ioutil.TempFile("", "test")
-			require.NoError(t, err)
-			defer tmp.Close()
-
-			_, err = tmp.Write([]byte(tt.content))
-			require.NoError(t, err)
-
-			req := require.New(t)
-
-			got, err := findProbableFileInWhatMightBeAnArchive(tmp.Name())
-			if tt.wantErr == nil {
-				req.NoError(err)
-				assert.Equal(t, tt.want, got)
-			} else {
-				assert.EqualError(t, err, tt.wantErr.Error())
-			}
-		})
-	}
-}
-
-func Test_isLikelyFile(t *testing.T) {
-	tests := []struct {
-		name                  string
-		mode                  int64
-		filename              string
-		currentExecutableName string
-		want                  bool
-	}{
-		{
-			name:                  "executable",
-			mode:                  0755,
-			filename:              "foo",
-			currentExecutableName: "foo",
-			want:                  true,
-		},
-		{
-			name:                  "not executable",
-			mode:                  0444,
-			filename:              "foo",
-			currentExecutableName: "foo",
-			want:                  false,
-		},
-		{
-			name:                  "executable, wrong filename",
-			mode:                  0755,
-			filename:              "foo2",
-			currentExecutableName: "foo",
-			want:                  false,
-		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got := isLikelyFile(tt.mode, tt.filename, tt.currentExecutableName)
-			assert.Equal(t, tt.want, got)
-		})
-	}
-}
-
 func Test_bestAsset(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -268,3 +205,569 @@ func Test_checksum(t *testing.T) {
 		})
 	}
 }
+
+func Test_NewGitHubUpdateChecker(t *testing.T) {
+	tests := []struct {
+		name      string
+		fqRepo    string
+		wantHost  string
+		wantOwner string
+		wantRepo  string
+	}{
+		{
+			name:      "owner/repo",
+			fqRepo:    "usrbinapp/cli",
+			wantHost:  "https://api.github.com",
+			wantOwner: "usrbinapp",
+			wantRepo:  "cli",
+		},
+		{
+			name:      "github.com/owner/repo",
+			fqRepo:    "github.com/usrbinapp/cli",
+			wantHost:  "https://api.github.com",
+			wantOwner: "usrbinapp",
+			wantRepo:  "cli",
+		},
+		{
+			name:      "enterprise host/owner/repo",
+			fqRepo:    "github.example.com/usrbinapp/cli",
+			wantHost:  "https://github.example.com/api/v3",
+			wantOwner: "usrbinapp",
+			wantRepo:  "cli",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewGitHubUpdateChecker(tt.fqRepo).(GitHubUpdateChecker)
+			assert.Equal(t, tt.wantHost, got.host)
+			assert.Equal(t, tt.wantOwner, got.parsedRepo.owner)
+			assert.Equal(t, tt.wantRepo, got.parsedRepo.repo)
+		})
+	}
+}
+
+func Test_NewGitHubEnterpriseUpdateChecker(t *testing.T) {
+	tests := []struct {
+		name     string
+		baseURL  string
+		wantHost string
+	}{
+		{
+			name:     "base url",
+			baseURL:  "https://github.example.com",
+			wantHost: "https://github.example.com/api/v3",
+		},
+		{
+			name:     "api url",
+			baseURL:  "https://github.example.com/api/v3/",
+			wantHost: "https://github.example.com/api/v3",
+		},
+		{
+			name:     "no scheme",
+			baseURL:  "github.example.com",
+			wantHost: "https://github.example.com/api/v3",
+		},
+		{
+			name:     "plain http",
+			baseURL:  "http://127.0.0.1:8080",
+			wantHost: "http://127.0.0.1:8080/api/v3",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewGitHubEnterpriseUpdateChecker(tt.baseURL, "usrbinapp/cli").(GitHubUpdateChecker)
+			assert.Equal(t, tt.wantHost, got.host)
+			assert.Equal(t, "usrbinapp", got.parsedRepo.owner)
+			assert.Equal(t, "cli", got.parsedRepo.repo)
+		})
+	}
+}
+
+func Test_tokenFromEnv(t *testing.T) {
+	tests := []struct {
+		name string
+		host string
+		env  map[string]string
+		want string
+	}{
+		{
+			name: "github.com",
+			host: DefaultAPIHost,
+			env:  map[string]string{"GITHUB_TOKEN": "github", "GH_ENTERPRISE_TOKEN": "enterprise"},
+			want: "github",
+		},
+		{
+			name: "github.com prefers GH_TOKEN",
+			host: DefaultAPIHost,
+			env:  map[string]string{"GH_TOKEN": "gh", "GITHUB_TOKEN": "github"},
+			want: "gh",
+		},
+		{
+			name: "enterprise",
+			host: "https://github.example.com/api/v3",
+			env:  map[string]string{"GITHUB_TOKEN": "github", "GITHUB_ENTERPRISE_TOKEN": "enterprise"},
+			want: "enterprise",
+		},
+		{
+			name: "enterprise falls back to GITHUB_TOKEN",
+			host: "https://github.example.com/api/v3",
+			env:  map[string]string{"GITHUB_TOKEN": "github"},
+			want: "github",
+		},
+		{
+			name: "no token",
+			host: "https://github.example.com/api/v3",
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, envVar := range []string{"GH_TOKEN", "GITHUB_TOKEN", "GH_ENTERPRISE_TOKEN", "GITHUB_ENTERPRISE_TOKEN"} {
+				t.Setenv(envVar, tt.env[envVar])
+			}
+
+			assert.Equal(t, tt.want, tokenFromEnv(tt.host))
+		})
+	}
+}
+
+func Test_GetLatestVersionEnterprise(t *testing.T) {
+	req := require.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v3/repos/usrbinapp/cli/releases/latest", r.URL.Path)
+		fmt.Fprint(w, `{"tag_name": "v1.2.0", "published_at": "2023-01-01T00:00:00Z"}`)
+	}))
+	defer server.Close()
+
+	c := NewGitHubEnterpriseUpdateChecker(server.URL, "usrbinapp/cli")
+	got, err := c.GetLatestVersion(time.Second)
+	req.NoError(err)
+	assert.Equal(t, "v1.2.0", got.Version)
+}
+
+func Test_GetLatestVersionChannel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v3/repos/usrbinapp/cli/releases/latest":
+			fmt.Fprint(w, `{"tag_name": "v1.1.0", "published_at": "2023-01-01T00:00:00Z"}`)
+		case "/api/v3/repos/usrbinapp/cli/releases":
+			fmt.Fprint(w, `[
+				{"tag_name": "v1.3.0-beta.1", "draft": true, "prerelease": true},
+				{"tag_name": "v1.2.0-rc.1", "prerelease": true},
+				{"tag_name": "v1.2.0-beta.2", "prerelease": true},
+				{"tag_name": "v1.1.0"},
+				{"tag_name": "v1.0.0"}
+			]`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		channel string
+		want    string
+	}{
+		{channel: "", want: "v1.1.0"},
+		{channel: "stable", want: "v1.1.0"},
+		{channel: "beta", want: "v1.2.0-rc.1"},
+		{channel: "edge", want: "v1.1.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.channel, func(t *testing.T) {
+			c := NewGitHubEnterpriseUpdateChecker(server.URL, "usrbinapp/cli", WithChannel(tt.channel))
+			got, err := c.GetLatestVersion(time.Second)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got.Version)
+		})
+	}
+}
+
+func Test_GetLatestVersionConstraint(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v3/repos/usrbinapp/cli/releases", r.URL.Path)
+
+		if r.URL.Query().Get("page") == "2" {
+			fmt.Fprint(w, `[
+				{"tag_name": "v1.9.0", "published_at": "2023-01-01T00:00:00Z"},
+				{"tag_name": "v1.8.0", "published_at": "2022-12-01T00:00:00Z"}
+			]`)
+			return
+		}
+
+		w.Header().Set("Link", fmt.Sprintf(`<%s/api/v3/repos/usrbinapp/cli/releases?per_page=100&page=2>; rel="next"`, server.URL))
+		fmt.Fprint(w, `[
+			{"tag_name": "v2.1.0", "published_at": "2023-03-01T00:00:00Z"},
+			{"tag_name": "v2.0.0", "published_at": "2023-02-01T00:00:00Z"}
+		]`)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		constraint  string
+		wantVersion string
+	}{
+		{constraint: "<3.0", wantVersion: "v2.1.0"},
+		{constraint: "<2.0", wantVersion: "v1.9.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.constraint, func(t *testing.T) {
+			constraint, err := semver.NewConstraint(tt.constraint)
+			require.NoError(t, err)
+
+			c := NewGitHubEnterpriseUpdateChecker(server.URL, "usrbinapp/cli", WithVersionConstraint(constraint))
+			got, err := c.GetLatestVersion(time.Second)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantVersion, got.Version)
+		})
+	}
+}
+
+func Test_ListVersions(t *testing.T) {
+	req := require.New(t)
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v3/repos/usrbinapp/cli/releases", r.URL.Path)
+
+		switch r.URL.Query().Get("page") {
+		case "":
+			w.Header().Set("Link", fmt.Sprintf(`<%s/api/v3/repos/usrbinapp/cli/releases?per_page=100&page=2>; rel="next", <%s/api/v3/repos/usrbinapp/cli/releases?per_page=100&page=2>; rel="last"`, server.URL, server.URL))
+			fmt.Fprint(w, `[
+				{"tag_name": "v1.3.0-beta.1", "prerelease": true},
+				{"tag_name": "v1.2.0"},
+				{"tag_name": "v1.10.0"}
+			]`)
+		case "2":
+			fmt.Fprint(w, `[
+				{"tag_name": "v1.11.0", "draft": true},
+				{"tag_name": "v1.1.0"}
+			]`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	c := NewGitHubEnterpriseUpdateChecker(server.URL, "usrbinapp/cli").(updatechecker.VersionLister)
+	versions, err := c.ListVersions(time.Second)
+	req.NoError(err)
+
+	got := []string{}
+	for _, v := range versions {
+		got = append(got, v.Version)
+	}
+	assert.Equal(t, []string{"v1.1.0", "v1.2.0", "v1.10.0"}, got)
+}
+
+func Test_GetLatestVersionContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		ctx     func() (context.Context, context.CancelFunc)
+		wantErr error
+	}{
+		{
+			name: "cancelled",
+			ctx: func() (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(context.Background())
+				time.AfterFunc(time.Millisecond*50, cancel)
+				return ctx, cancel
+			},
+			wantErr: context.Canceled,
+		},
+		{
+			name: "deadline exceeded",
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), time.Millisecond*50)
+			},
+			wantErr: ErrTimeoutExceeded,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := tt.ctx()
+			defer cancel()
+
+			c := NewGitHubEnterpriseUpdateChecker(server.URL, "usrbinapp/cli").(updatechecker.ContextUpdateChecker)
+			_, err := c.GetLatestVersionContext(ctx)
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func Test_DownloadVersionWithToken(t *testing.T) {
+	req := require.New(t)
+
+	archiveContents, _, err := archivetest.CreateTGZWithExecutable([]byte("new version"))
+	req.NoError(err)
+
+	assetName := fmt.Sprintf("cli_%s_%s.tar.gz", runtime.GOOS, runtime.GOARCH)
+
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	mux.HandleFunc("/api/v3/repos/usrbinapp/cli/releases/tags/v1.0.0", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		fmt.Fprintf(w, `{
+			"tag_name": "v1.0.0",
+			"assets": [
+				{
+					"url": "%[1]s/api/v3/repos/usrbinapp/cli/releases/assets/1",
+					"name": "%[2]s",
+					"state": "uploaded",
+					"browser_download_url": "%[1]s/usrbinapp/cli/releases/download/v1.0.0/%[2]s"
+				}
+			]
+		}`, server.URL, assetName)
+	})
+	mux.HandleFunc("/api/v3/repos/usrbinapp/cli/releases/assets/1", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" || r.Header.Get("Accept") != "application/octet-stream" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Write(archiveContents)
+	})
+
+	c := NewGitHubEnterpriseUpdateChecker(server.URL, "usrbinapp/cli", WithToken("secret"))
+	got, err := c.DownloadVersion("v1.0.0", true)
+	req.NoError(err)
+	defer os.Remove(got)
+
+	contents, err := ioutil.ReadFile(got)
+	req.NoError(err)
+	assert.Equal(t, "new version", string(contents))
+
+	c = NewGitHubEnterpriseUpdateChecker(server.URL, "usrbinapp/cli", WithToken(""))
+	_, err = c.DownloadVersion("v1.0.0", true)
+	assert.ErrorIs(t, err, ErrReleaseNotFound)
+}
+
+// recordingTransport records the path of each request before sending it
+type recordingTransport struct {
+	mu    sync.Mutex
+	paths []string
+}
+
+func (t *recordingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	t.paths = append(t.paths, r.URL.Path)
+	t.mu.Unlock()
+
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func Test_WithHTTPClient(t *testing.T) {
+	req := require.New(t)
+
+	archiveContents, archiveChecksum, err := archivetest.CreateTGZWithExecutable([]byte("new version"))
+	req.NoError(err)
+
+	assetName := fmt.Sprintf("cli_%s_%s.tar.gz", runtime.GOOS, runtime.GOARCH)
+
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	mux.HandleFunc("/api/v3/repos/usrbinapp/cli/releases/latest", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"tag_name": "v1.0.0", "published_at": "2023-01-01T00:00:00Z"}`)
+	})
+	mux.HandleFunc("/api/v3/repos/usrbinapp/cli/releases/tags/v1.0.0", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{
+			"tag_name": "v1.0.0",
+			"assets": [
+				{"name": "%[2]s", "state": "uploaded", "browser_download_url": "%[1]s/download/%[2]s"},
+				{"name": "checksums.txt", "state": "uploaded", "browser_download_url": "%[1]s/download/checksums.txt"}
+			]
+		}`, server.URL, assetName)
+	})
+	mux.HandleFunc("/download/"+assetName, func(w http.ResponseWriter, r *http.Request) {
+		w.Write(archiveContents)
+	})
+	mux.HandleFunc("/download/checksums.txt", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s  %s\n", archiveChecksum, assetName)
+	})
+
+	transport := &recordingTransport{}
+	c := NewGitHubEnterpriseUpdateChecker(server.URL, "usrbinapp/cli", WithHTTPClient(&http.Client{Transport: transport}))
+
+	_, err = c.GetLatestVersion(time.Second)
+	req.NoError(err)
+
+	got, err := c.DownloadVersion("v1.0.0", true)
+	req.NoError(err)
+	defer os.Remove(got)
+
+	assert.Equal(t, []string{
+		"/api/v3/repos/usrbinapp/cli/releases/latest",
+		"/api/v3/repos/usrbinapp/cli/releases/tags/v1.0.0",
+		"/download/checksums.txt",
+		"/download/" + assetName,
+	}, transport.paths)
+}
+
+func Test_GetLatestVersionRateLimited(t *testing.T) {
+	reset := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	tests := []struct {
+		name      string
+		status    int
+		headers   map[string]string
+		wantReset time.Time
+	}{
+		{
+			name:   "primary rate limit",
+			status: http.StatusForbidden,
+			headers: map[string]string{
+				"X-RateLimit-Remaining": "0",
+				"X-RateLimit-Reset":     fmt.Sprintf("%d", reset.Unix()),
+			},
+			wantReset: reset,
+		},
+		{
+			name:   "primary rate limit, too many requests",
+			status: http.StatusTooManyRequests,
+			headers: map[string]string{
+				"X-RateLimit-Remaining": "0",
+				"X-RateLimit-Reset":     fmt.Sprintf("%d", reset.Unix()),
+			},
+			wantReset: reset,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := require.New(t)
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for k, v := range tt.headers {
+					w.Header().Set(k, v)
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			c := NewGitHubEnterpriseUpdateChecker(server.URL, "usrbinapp/cli")
+			_, err := c.GetLatestVersion(time.Second)
+
+			var rateLimitedErr *RateLimitedError
+			req.ErrorAs(err, &rateLimitedErr)
+			assert.True(t, tt.wantReset.Equal(rateLimitedErr.Reset))
+			assert.Equal(t, 0, rateLimitedErr.Remaining)
+		})
+	}
+}
+
+func Test_rateLimitedError(t *testing.T) {
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		status  int
+		headers map[string]string
+		want    *RateLimitedError
+	}{
+		{
+			name:   "forbidden, not rate limited",
+			status: http.StatusForbidden,
+			headers: map[string]string{
+				"X-RateLimit-Remaining": "10",
+			},
+			want: nil,
+		},
+		{
+			name:   "not forbidden",
+			status: http.StatusInternalServerError,
+			headers: map[string]string{
+				"X-RateLimit-Remaining": "0",
+			},
+			want: nil,
+		},
+		{
+			name:   "rate limit reset",
+			status: http.StatusForbidden,
+			headers: map[string]string{
+				"X-RateLimit-Remaining": "0",
+				"X-RateLimit-Reset":     fmt.Sprintf("%d", now.Add(time.Minute*5).Unix()),
+			},
+			want: &RateLimitedError{Reset: now.Add(time.Minute * 5)},
+		},
+		{
+			name:   "secondary rate limit",
+			status: http.StatusForbidden,
+			headers: map[string]string{
+				"X-RateLimit-Remaining": "42",
+				"Retry-After":           "60",
+			},
+			want: &RateLimitedError{Remaining: 42, Reset: now.Add(time.Minute)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{StatusCode: tt.status, Header: http.Header{}}
+			for k, v := range tt.headers {
+				resp.Header.Set(k, v)
+			}
+
+			got := rateLimitedError(resp, now)
+			if tt.want == nil {
+				assert.Nil(t, got)
+				return
+			}
+
+			require.NotNil(t, got)
+			assert.Equal(t, tt.want.Remaining, got.Remaining)
+			assert.True(t, tt.want.Reset.Equal(got.Reset))
+		})
+	}
+}
+
+func Test_GetLatestVersionCached(t *testing.T) {
+	req := require.New(t)
+
+	cacheDir, err := ioutil.TempDir("", "usrbin")
+	req.NoError(err)
+	defer os.RemoveAll(cacheDir)
+
+	requests := 0
+	notModified := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("ETag", `"v1"`)
+		fmt.Fprint(w, `{"tag_name": "v1.2.0", "published_at": "2023-01-01T00:00:00Z"}`)
+	}))
+	defer server.Close()
+
+	c := NewGitHubEnterpriseUpdateChecker(server.URL, "usrbinapp/cli", WithCacheDir(cacheDir))
+	for i := 0; i < 3; i++ {
+		got, err := c.GetLatestVersion(time.Second)
+		req.NoError(err)
+		assert.Equal(t, "v1.2.0", got.Version)
+	}
+
+	assert.Equal(t, 3, requests)
+	assert.Equal(t, 2, notModified)
+
+	// without a cache dir, every request is unconditional
+	c = NewGitHubEnterpriseUpdateChecker(server.URL, "usrbinapp/cli")
+	_, err = c.GetLatestVersion(time.Second)
+	req.NoError(err)
+	assert.Equal(t, 2, notModified)
+}
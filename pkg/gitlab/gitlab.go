@@ -0,0 +1,316 @@
+package gitlab
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver"
+	"github.com/pkg/errors"
+	"github.com/usrbinapp/usrbin-go/pkg/release"
+	"github.com/usrbinapp/usrbin-go/pkg/retry"
+	"github.com/usrbinapp/usrbin-go/pkg/updatechecker"
+)
+
+const (
+	DefaultHost = "https://gitlab.com"
+)
+
+var (
+	ErrReleaseNotFound = errors.New("release not found")
+	ErrTimeoutExceeded = release.ErrTimeoutExceeded
+)
+
+type GitLabUpdateChecker struct {
+	timeout time.Duration
+
+	host string
+
+	project string
+
+	httpClient  *http.Client
+	retryPolicy retry.Policy
+
+	channel           string
+	versionConstraint *semver.Constraints
+}
+
+var _ updatechecker.UpdateChecker = (*GitLabUpdateChecker)(nil)
+var _ updatechecker.ContextUpdateChecker = (*GitLabUpdateChecker)(nil)
+var _ updatechecker.VersionLister = (*GitLabUpdateChecker)(nil)
+
+// Option is a functional option for configuring the update checker
+type Option func(*GitLabUpdateChecker)
+
+// WithHTTPClient will make all requests, including downloads, with the
+// http client passed in instead of http.DefaultClient
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *GitLabUpdateChecker) {
+		if httpClient != nil {
+			c.httpClient = httpClient
+		}
+	}
+}
+
+// WithRetryPolicy will set how failed requests are retried, instead of
+// retry.DefaultPolicy
+func WithRetryPolicy(policy retry.Policy) Option {
+	return func(c *GitLabUpdateChecker) {
+		c.retryPolicy = policy
+	}
+}
+
+// WithChannel will offer the releases in the channel (stable, rc, beta,
+// alpha, nightly or any other name). see updatechecker.InChannel
+func WithChannel(channel string) Option {
+	return func(c *GitLabUpdateChecker) {
+		c.channel = channel
+	}
+}
+
+// WithVersionConstraint will only offer the releases that satisfy
+// constraint. see updatechecker.SatisfiesConstraint
+func WithVersionConstraint(constraint *semver.Constraints) Option {
+	return func(c *GitLabUpdateChecker) {
+		c.versionConstraint = constraint
+	}
+}
+
+type gitLabReleaseLink struct {
+	ID             int    `json:"id"`
+	Name           string `json:"name"`
+	URL            string `json:"url"`
+	DirectAssetURL string `json:"direct_asset_url"`
+	LinkType       string `json:"link_type"`
+}
+
+type gitLabReleaseInfo struct {
+	TagName         string    `json:"tag_name"`
+	ReleasedAt      time.Time `json:"released_at"`
+	UpcomingRelease bool      `json:"upcoming_release"`
+	Assets          struct {
+		Links []gitLabReleaseLink `json:"links"`
+	} `json:"assets"`
+}
+
+// NewGitLabUpdateChecker will return an update checker that reads releases
+// from the GitLab project (for example "group/subgroup/project") on host.
+// host is the base url of the GitLab instance, and defaults to gitlab.com
+// when empty
+func NewGitLabUpdateChecker(host string, project string, opts ...Option) updatechecker.UpdateChecker {
+	if host == "" {
+		host = DefaultHost
+	}
+
+	trimmedProject := strings.Trim(project, "/")
+	if trimmedProject == "" {
+		panic(fmt.Sprintf("invalid project: %q", project))
+	}
+
+	c := GitLabUpdateChecker{
+		host:        strings.TrimRight(host, "/"),
+		project:     trimmedProject,
+		timeout:     time.Second * 3, // a default
+		httpClient:  http.DefaultClient,
+		retryPolicy: retry.DefaultPolicy,
+	}
+
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	c.httpClient = retry.NewClient(c.httpClient, c.retryPolicy)
+
+	return c
+}
+
+// DownloadVersion will download and extract the specific version, returning
+// a path to the extracted file in the archive
+// it's the responsibility of the caller to clean up the extracted file
+func (c GitLabUpdateChecker) DownloadVersion(version string, requireChecksumMatch bool) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	return c.DownloadVersionContext(ctx, version, requireChecksumMatch)
+}
+
+// DownloadVersionContext is DownloadVersion, stopping when ctx is done
+func (c GitLabUpdateChecker) DownloadVersionContext(ctx context.Context, version string, requireChecksumMatch bool) (string, error) {
+	releaseInfo, err := getReleaseDetails(ctx, c.httpClient, c.host, c.project, version)
+	if err != nil {
+		return "", errors.Wrap(err, "get release details")
+	}
+
+	assets := toReleaseAssets(releaseInfo.Assets.Links)
+
+	asset, err := release.BestAsset(assets, runtime.GOOS, runtime.GOARCH)
+	if err != nil {
+		return "", errors.Wrap(err, "best asset")
+	}
+
+	checksumAsset := release.ChecksumAsset(assets, asset.Name)
+
+	fileInArchivePath, err := release.DownloadAndVerify(ctx, c.httpClient, *asset, checksumAsset)
+	if err != nil {
+		return "", errors.Wrap(err, "download and verify")
+	}
+
+	return fileInArchivePath, nil
+}
+
+// GetLatestVersion will return the latest version information from the gitlab project
+func (c GitLabUpdateChecker) GetLatestVersion(timeout time.Duration) (*updatechecker.VersionInfo, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return c.GetLatestVersionContext(ctx)
+}
+
+// GetLatestVersionContext is GetLatestVersion, stopping when ctx is done.
+// the latest version is the highest semver of every release, not the most
+// recently released, so that a hotfix to an older line doesn't win
+func (c GitLabUpdateChecker) GetLatestVersionContext(ctx context.Context) (*updatechecker.VersionInfo, error) {
+	versions, err := c.ListVersionsContext(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "list versions")
+	}
+
+	if len(versions) == 0 {
+		return nil, ErrReleaseNotFound
+	}
+
+	// versions are sorted by semver, oldest first
+	latestVersion := versions[len(versions)-1]
+	return &latestVersion, nil
+}
+
+// ListVersions will return every release in the channel that satisfies
+// the constraint, reading every page of releases
+func (c GitLabUpdateChecker) ListVersions(timeout time.Duration) ([]updatechecker.VersionInfo, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return c.ListVersionsContext(ctx)
+}
+
+// ListVersionsContext is ListVersions, stopping when ctx is done
+func (c GitLabUpdateChecker) ListVersionsContext(ctx context.Context) ([]updatechecker.VersionInfo, error) {
+	versions := []updatechecker.VersionInfo{}
+
+	uri := releasesURI(c.host, c.project)
+	for uri != "" {
+		releases := []gitLabReleaseInfo{}
+		nextURI, err := getJSONPage(ctx, c.httpClient, uri, &releases)
+		if err != nil {
+			return nil, errors.Wrap(err, "list releases")
+		}
+
+		for _, releaseInfo := range releases {
+			if !offers(releaseInfo, c.channel, c.versionConstraint) {
+				continue
+			}
+
+			releasedAt := releaseInfo.ReleasedAt
+			versions = append(versions, updatechecker.VersionInfo{
+				Version:    releaseInfo.TagName,
+				ReleasedAt: &releasedAt,
+			})
+		}
+
+		uri = nextURI
+	}
+
+	updatechecker.SortVersions(versions)
+	return versions, nil
+}
+
+// toReleaseAssets will convert the release links into assets, preferring
+// the permanent direct asset url when gitlab provides one
+func toReleaseAssets(links []gitLabReleaseLink) []release.Asset {
+	assets := []release.Asset{}
+	for _, link := range links {
+		downloadURL := link.DirectAssetURL
+		if downloadURL == "" {
+			downloadURL = link.URL
+		}
+
+		assets = append(assets, release.Asset{
+			Name: link.Name,
+			URL:  downloadURL,
+		})
+	}
+
+	return assets
+}
+
+// getReleaseDetails will return the release for the tag releaseName
+func getReleaseDetails(ctx context.Context, httpClient *http.Client, host string, project string, releaseName string) (*gitLabReleaseInfo, error) {
+	uri := fmt.Sprintf("%s/api/v4/projects/%s/releases/%s", host, url.PathEscape(project), url.PathEscape(releaseName))
+
+	releaseInfo := gitLabReleaseInfo{}
+	if err := getJSON(ctx, httpClient, uri, &releaseInfo); err != nil {
+		return nil, err
+	}
+
+	return &releaseInfo, nil
+}
+
+// offers will return true when the release is in channel, satisfies
+// constraint and isn't upcoming. upcoming releases have a released_at
+// in the future and shouldn't be offered yet
+func offers(releaseInfo gitLabReleaseInfo, channel string, constraint *semver.Constraints) bool {
+	if releaseInfo.UpcomingRelease || !updatechecker.InChannel(releaseInfo.TagName, false, channel) {
+		return false
+	}
+
+	return updatechecker.SatisfiesConstraint(releaseInfo.TagName, constraint)
+}
+
+// releasesURI is the first page of releases
+func releasesURI(host string, project string) string {
+	return fmt.Sprintf("%s/api/v4/projects/%s/releases?per_page=100", host, url.PathEscape(project))
+}
+
+func getJSON(ctx context.Context, httpClient *http.Client, uri string, v interface{}) error {
+	_, err := getJSONPage(ctx, httpClient, uri, v)
+	return err
+}
+
+// getJSONPage is getJSON, also returning the uri of the next page of a
+// paginated response, or "" when it's the last page
+func getJSONPage(ctx context.Context, httpClient *http.Client, uri string, v interface{}) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", uri, nil)
+	if err != nil {
+		return "", errors.Wrap(err, "new request")
+	}
+
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		if release.TimeoutError(err) == ErrTimeoutExceeded {
+			return "", ErrTimeoutExceeded
+		}
+		return "", errors.Wrap(err, "do request")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		if resp.StatusCode == http.StatusNotFound {
+			return "", ErrReleaseNotFound
+		}
+
+		return "", errors.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return "", errors.Wrap(err, "decode response")
+	}
+
+	return release.NextPageURL(resp.Header.Get("Link")), nil
+}
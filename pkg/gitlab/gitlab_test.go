@@ -0,0 +1,194 @@
+package gitlab
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/Masterminds/semver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/usrbinapp/usrbin-go/internal/archivetest"
+	"github.com/usrbinapp/usrbin-go/pkg/release"
+)
+
+func Test_GetLatestVersion(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		body        string
+		wantVersion string
+		wantErr     error
+	}{
+		{
+			name:   "skips upcoming releases",
+			status: http.StatusOK,
+			body: `[
+				{"tag_name": "v1.1.0", "released_at": "2099-01-01T00:00:00Z", "upcoming_release": true},
+				{"tag_name": "v1.0.0", "released_at": "2023-01-01T00:00:00Z", "upcoming_release": false}
+			]`,
+			wantVersion: "v1.0.0",
+		},
+		{
+			name:   "highest semver wins over a later hotfix",
+			status: http.StatusOK,
+			body: `[
+				{"tag_name": "v1.9.1", "released_at": "2023-03-01T00:00:00Z"},
+				{"tag_name": "v2.0.0", "released_at": "2023-02-01T00:00:00Z"},
+				{"tag_name": "v1.9.0", "released_at": "2023-01-01T00:00:00Z"}
+			]`,
+			wantVersion: "v2.0.0",
+		},
+		{
+			name:    "no releases",
+			status:  http.StatusOK,
+			body:    `[]`,
+			wantErr: ErrReleaseNotFound,
+		},
+		{
+			name:    "project not found",
+			status:  http.StatusNotFound,
+			body:    `{"message": "404 Project Not Found"}`,
+			wantErr: ErrReleaseNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := require.New(t)
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/api/v4/projects/group%2Fsubgroup%2Fproject/releases", r.URL.EscapedPath())
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			}))
+			defer server.Close()
+
+			c := NewGitLabUpdateChecker(server.URL, "group/subgroup/project")
+			got, err := c.GetLatestVersion(time.Second)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			req.NoError(err)
+			assert.Equal(t, tt.wantVersion, got.Version)
+		})
+	}
+}
+
+func Test_GetLatestVersionConstraint(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "2" {
+			fmt.Fprint(w, `[
+				{"tag_name": "v1.9.0", "released_at": "2023-01-01T00:00:00Z"},
+				{"tag_name": "v1.8.0", "released_at": "2022-12-01T00:00:00Z"}
+			]`)
+			return
+		}
+
+		w.Header().Set("Link", fmt.Sprintf(`<%s/api/v4/projects/project/releases?per_page=100&page=2>; rel="next"`, server.URL))
+		fmt.Fprint(w, `[
+			{"tag_name": "v2.1.0", "released_at": "2023-03-01T00:00:00Z"},
+			{"tag_name": "v2.0.0", "released_at": "2023-02-01T00:00:00Z"}
+		]`)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		constraint  string
+		wantVersion string
+	}{
+		{constraint: "", wantVersion: "v2.1.0"},
+		{constraint: "<2.0", wantVersion: "v1.9.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.constraint, func(t *testing.T) {
+			opts := []Option{}
+			if tt.constraint != "" {
+				constraint, err := semver.NewConstraint(tt.constraint)
+				require.NoError(t, err)
+				opts = append(opts, WithVersionConstraint(constraint))
+			}
+
+			c := NewGitLabUpdateChecker(server.URL, "project", opts...)
+			got, err := c.GetLatestVersion(time.Second)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantVersion, got.Version)
+		})
+	}
+}
+
+func Test_DownloadVersion(t *testing.T) {
+	req := require.New(t)
+
+	archiveContents, archiveChecksum, err := archivetest.CreateTGZWithExecutable([]byte("new version"))
+	req.NoError(err)
+
+	assetName := fmt.Sprintf("cli_%s_%s.tar.gz", runtime.GOOS, runtime.GOARCH)
+
+	tests := []struct {
+		name     string
+		checksum string
+		wantErr  error
+	}{
+		{
+			name:     "checksum matches",
+			checksum: archiveChecksum,
+		},
+		{
+			name:     "checksum mismatch",
+			checksum: "0000000000000000000000000000000000000000000000000000000000000000",
+			wantErr:  release.ErrChecksumMismatch,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := require.New(t)
+
+			mux := http.NewServeMux()
+			server := httptest.NewServer(mux)
+			defer server.Close()
+
+			mux.HandleFunc("/api/v4/projects/", func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/api/v4/projects/cli%2Fcli/releases/v1.0.0", r.URL.EscapedPath())
+				fmt.Fprintf(w, `{
+					"tag_name": "v1.0.0",
+					"released_at": "2023-01-01T00:00:00Z",
+					"assets": {
+						"links": [
+							{"name": "cli_other_other.tar.gz", "url": "%[1]s/other"},
+							{"name": "%[2]s", "url": "%[1]s/not-used", "direct_asset_url": "%[1]s/archive"},
+							{"name": "checksums.txt", "url": "%[1]s/checksums.txt"}
+						]
+					}
+				}`, server.URL, assetName)
+			})
+			mux.HandleFunc("/archive", func(w http.ResponseWriter, r *http.Request) {
+				w.Write(archiveContents)
+			})
+			mux.HandleFunc("/checksums.txt", func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintf(w, "%s  %s\n", tt.checksum, assetName)
+			})
+
+			c := NewGitLabUpdateChecker(server.URL, "cli/cli")
+			got, err := c.DownloadVersion("v1.0.0", true)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			req.NoError(err)
+			defer os.Remove(got)
+
+			contents, err := ioutil.ReadFile(got)
+			req.NoError(err)
+			assert.Equal(t, "new version", string(contents))
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package homebrew
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os/exec"
@@ -14,6 +15,7 @@ type HomebrewExternalPackageManager struct {
 }
 
 var _ pkgmgr.ExternalPackageManager = (*HomebrewExternalPackageManager)(nil)
+var _ pkgmgr.ContextExternalPackageManager = (*HomebrewExternalPackageManager)(nil)
 
 type homebrewInfoOutput struct {
 	Installed []struct {
@@ -33,13 +35,19 @@ func (m HomebrewExternalPackageManager) UpgradeCommand() string {
 
 // IsInstalled will return true if the formula is installed using homebrew
 func (m HomebrewExternalPackageManager) IsInstalled() (bool, error) {
+	return m.IsInstalledContext(context.Background())
+}
+
+// IsInstalledContext is IsInstalled, killing brew when ctx is done
+func (m HomebrewExternalPackageManager) IsInstalledContext(ctx context.Context) (bool, error) {
 	path, err := exec.LookPath("brew")
 	if err != nil {
 		// we just assume that it wasn't installed via brew if there's no brew command
 		return false, nil
 	}
 
-	out, err := exec.Command(
+	out, err := exec.CommandContext(
+		ctx,
 		path,
 		"info",
 		m.formula,
@@ -52,6 +60,9 @@ func (m HomebrewExternalPackageManager) IsInstalled() (bool, error) {
 				return false, nil
 			}
 		}
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
 		return false, errors.Wrap(err, "exec brew")
 	}
 
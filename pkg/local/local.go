@@ -0,0 +1,274 @@
+package local
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver"
+	"github.com/pkg/errors"
+	"github.com/usrbinapp/usrbin-go/pkg/release"
+	"github.com/usrbinapp/usrbin-go/pkg/updatechecker"
+)
+
+var (
+	ErrReleaseNotFound = errors.New("release not found")
+)
+
+// LocalUpdateChecker reads releases from a directory on the local filesystem
+// (or a network share mounted on it). the directory has one subdirectory
+// per version, named after the version, that holds the archives and
+// checksum files for that release:
+//
+//	/mnt/releases/cli/
+//	  v1.2.0/
+//	    cli_linux_amd64.tar.gz
+//	    cli_darwin_all.tar.gz
+//	    checksums.txt
+//	  v1.3.0/
+//	    ...
+type LocalUpdateChecker struct {
+	dir string
+
+	channel           string
+	versionConstraint *semver.Constraints
+}
+
+var _ updatechecker.UpdateChecker = (*LocalUpdateChecker)(nil)
+var _ updatechecker.ContextUpdateChecker = (*LocalUpdateChecker)(nil)
+var _ updatechecker.VersionLister = (*LocalUpdateChecker)(nil)
+
+// Option is a functional option for configuring the update checker
+type Option func(*LocalUpdateChecker)
+
+// WithChannel will offer the versions in the channel (stable, rc, beta,
+// alpha, nightly or any other name). see updatechecker.InChannel
+func WithChannel(channel string) Option {
+	return func(c *LocalUpdateChecker) {
+		c.channel = channel
+	}
+}
+
+// WithVersionConstraint will only offer the versions that satisfy
+// constraint. see updatechecker.SatisfiesConstraint
+func WithVersionConstraint(constraint *semver.Constraints) Option {
+	return func(c *LocalUpdateChecker) {
+		c.versionConstraint = constraint
+	}
+}
+
+// NewLocalUpdateChecker will return an update checker that reads releases
+// from dir. dir can be a path or a file:// url
+func NewLocalUpdateChecker(dir string, opts ...Option) updatechecker.UpdateChecker {
+	if strings.HasPrefix(dir, "file://") {
+		localPath, err := release.LocalPath(dir)
+		if err != nil {
+			panic(fmt.Sprintf("invalid dir: %s", dir))
+		}
+		dir = localPath
+	}
+
+	c := LocalUpdateChecker{
+		dir: dir,
+	}
+
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	return c
+}
+
+// DownloadVersion will extract the specific version, returning
+// a path to the extracted file in the archive
+// it's the responsibility of the caller to clean up the extracted file
+func (c LocalUpdateChecker) DownloadVersion(version string, requireChecksumMatch bool) (string, error) {
+	return c.DownloadVersionContext(context.Background(), version, requireChecksumMatch)
+}
+
+// DownloadVersionContext is DownloadVersion, stopping when ctx is done
+func (c LocalUpdateChecker) DownloadVersionContext(ctx context.Context, version string, requireChecksumMatch bool) (string, error) {
+	versionDir, err := c.findVersionDir(version)
+	if err != nil {
+		return "", errors.Wrap(err, "find version dir")
+	}
+
+	assets, err := listAssets(versionDir)
+	if err != nil {
+		return "", errors.Wrap(err, "list assets")
+	}
+
+	asset, err := release.BestAsset(assets, runtime.GOOS, runtime.GOARCH)
+	if err != nil {
+		return "", errors.Wrap(err, "best asset")
+	}
+
+	checksumAsset := release.ChecksumAsset(assets, asset.Name)
+
+	fileInArchivePath, err := release.DownloadAndVerify(ctx, nil, *asset, checksumAsset)
+	if err != nil {
+		return "", errors.Wrap(err, "download and verify")
+	}
+
+	return fileInArchivePath, nil
+}
+
+// GetLatestVersion will return the version directory in the channel, that
+// satisfies the constraint, with the highest semver.
+// the release time is the modification time of that directory
+// the timeout is ignored, reading a directory can't be bounded
+func (c LocalUpdateChecker) GetLatestVersion(timeout time.Duration) (*updatechecker.VersionInfo, error) {
+	return c.GetLatestVersionContext(context.Background())
+}
+
+// GetLatestVersionContext is GetLatestVersion, returning early when ctx is
+// already done
+func (c LocalUpdateChecker) GetLatestVersionContext(ctx context.Context) (*updatechecker.VersionInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	entries, err := ioutil.ReadDir(c.dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "read dir")
+	}
+
+	var latestSemver *semver.Version
+	var latestEntry os.FileInfo
+	for _, entry := range entries {
+		if !c.offers(entry) {
+			continue
+		}
+
+		parsed, err := semver.NewVersion(entry.Name())
+		if err != nil {
+			continue
+		}
+
+		if latestSemver == nil || parsed.GreaterThan(latestSemver) {
+			latestSemver = parsed
+			latestEntry = entry
+		}
+	}
+
+	if latestEntry == nil {
+		return nil, ErrReleaseNotFound
+	}
+
+	releasedAt := latestEntry.ModTime()
+	latestVersion := &updatechecker.VersionInfo{
+		Version:    latestEntry.Name(),
+		ReleasedAt: &releasedAt,
+	}
+
+	return latestVersion, nil
+}
+
+// findVersionDir will return the directory for the version, allowing
+// "v1.2.0" to match a directory named "1.2.0" and the other way around
+func (c LocalUpdateChecker) findVersionDir(version string) (string, error) {
+	exact := filepath.Join(c.dir, version)
+	if info, err := os.Stat(exact); err == nil && info.IsDir() {
+		return exact, nil
+	}
+
+	wanted, err := semver.NewVersion(version)
+	if err != nil {
+		return "", ErrReleaseNotFound
+	}
+
+	entries, err := ioutil.ReadDir(c.dir)
+	if err != nil {
+		return "", errors.Wrap(err, "read dir")
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		parsed, err := semver.NewVersion(entry.Name())
+		if err != nil {
+			continue
+		}
+
+		if parsed.Equal(wanted) {
+			return filepath.Join(c.dir, entry.Name()), nil
+		}
+	}
+
+	return "", ErrReleaseNotFound
+}
+
+func listAssets(dir string) ([]release.Asset, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "read dir")
+	}
+
+	assets := []release.Asset{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		assets = append(assets, release.Asset{
+			Name:  entry.Name(),
+			URL:   release.FileURL(filepath.Join(dir, entry.Name())),
+			Local: true,
+		})
+	}
+
+	return assets, nil
+}
+
+// ListVersions will return every version directory in the channel that
+// satisfies the constraint.
+// the timeout is ignored, reading a directory can't be bounded
+func (c LocalUpdateChecker) ListVersions(timeout time.Duration) ([]updatechecker.VersionInfo, error) {
+	return c.ListVersionsContext(context.Background())
+}
+
+// ListVersionsContext is ListVersions, returning early when ctx is
+// already done
+func (c LocalUpdateChecker) ListVersionsContext(ctx context.Context) ([]updatechecker.VersionInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	entries, err := ioutil.ReadDir(c.dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "read dir")
+	}
+
+	versions := []updatechecker.VersionInfo{}
+	for _, entry := range entries {
+		if !c.offers(entry) {
+			continue
+		}
+
+		releasedAt := entry.ModTime()
+		versions = append(versions, updatechecker.VersionInfo{
+			Version:    entry.Name(),
+			ReleasedAt: &releasedAt,
+		})
+	}
+
+	updatechecker.SortVersions(versions)
+	return versions, nil
+}
+
+// offers will return true when entry is a version directory in the
+// channel that satisfies the constraint
+func (c LocalUpdateChecker) offers(entry os.FileInfo) bool {
+	if !entry.IsDir() || !updatechecker.InChannel(entry.Name(), false, c.channel) {
+		return false
+	}
+
+	return updatechecker.SatisfiesConstraint(entry.Name(), c.versionConstraint)
+}
@@ -0,0 +1,91 @@
+package local
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/usrbinapp/usrbin-go/internal/archivetest"
+	"github.com/usrbinapp/usrbin-go/pkg/release"
+)
+
+func Test_GetLatestVersion(t *testing.T) {
+	req := require.New(t)
+
+	dir, err := ioutil.TempDir("", "usrbin")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+
+	for _, version := range []string{"v1.9.0", "v1.10.0", "not-a-version"} {
+		req.NoError(os.Mkdir(filepath.Join(dir, version), 0755))
+	}
+	req.NoError(ioutil.WriteFile(filepath.Join(dir, "v2.0.0"), []byte("not a dir"), 0644))
+
+	for _, source := range []string{dir, release.FileURL(dir)} {
+		c := NewLocalUpdateChecker(source)
+		got, err := c.GetLatestVersion(time.Second)
+		req.NoError(err)
+		assert.Equal(t, "v1.10.0", got.Version)
+		assert.NotNil(t, got.ReleasedAt)
+	}
+}
+
+func Test_DownloadVersion(t *testing.T) {
+	req := require.New(t)
+
+	archiveContents, archiveChecksum, err := archivetest.CreateTGZWithExecutable([]byte("new version"))
+	req.NoError(err)
+
+	assetName := fmt.Sprintf("cli_%s_%s.tar.gz", runtime.GOOS, runtime.GOARCH)
+
+	tests := []struct {
+		name     string
+		checksum string
+		wantErr  error
+	}{
+		{
+			name:     "checksum matches",
+			checksum: archiveChecksum,
+		},
+		{
+			name:     "checksum mismatch",
+			checksum: "0000000000000000000000000000000000000000000000000000000000000000",
+			wantErr:  release.ErrChecksumMismatch,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := require.New(t)
+
+			dir, err := ioutil.TempDir("", "usrbin")
+			req.NoError(err)
+			defer os.RemoveAll(dir)
+
+			versionDir := filepath.Join(dir, "1.0.0")
+			req.NoError(os.Mkdir(versionDir, 0755))
+			req.NoError(ioutil.WriteFile(filepath.Join(versionDir, assetName), archiveContents, 0644))
+			req.NoError(ioutil.WriteFile(filepath.Join(versionDir, "cli_other_other.tar.gz"), []byte("other"), 0644))
+			req.NoError(ioutil.WriteFile(filepath.Join(versionDir, "checksums.txt"), []byte(fmt.Sprintf("%s  %s\n", tt.checksum, assetName)), 0644))
+
+			c := NewLocalUpdateChecker(dir)
+			got, err := c.DownloadVersion("v1.0.0", true)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			req.NoError(err)
+			defer os.Remove(got)
+
+			contents, err := ioutil.ReadFile(got)
+			req.NoError(err)
+			assert.Equal(t, "new version", string(contents))
+		})
+	}
+}
@@ -0,0 +1,392 @@
+// Package manifest implements an update checker that reads releases from a
+// static JSON document, so releases can be hosted on any web server, CDN or
+// object store bucket.
+//
+// The manifest looks like:
+//
+//	{
+//	  "schemaVersion": 1,
+//	  "versions": [
+//	    {
+//	      "version": "1.2.0",
+//	      "releasedAt": "2023-06-01T12:00:00Z",
+//	      "assets": [
+//	        {
+//	          "os": "linux",
+//	          "arch": "amd64",
+//	          "url": "https://cdn.example.com/cli/1.2.0/cli_linux_amd64.tar.gz",
+//	          "sha256": "5f2b...e1"
+//	        },
+//	        {
+//	          "os": "darwin",
+//	          "arch": "all",
+//	          "url": "1.2.0/cli_darwin_all.tar.gz",
+//	          "sha256": "9ac0...7d"
+//	        }
+//	      ]
+//	    }
+//	  ]
+//	}
+//
+// version is the release's version and releasedAt is when it was released,
+// in RFC 3339 format. versions can be in any order, the latest is the highest semver in the channel (a
+// version with a prerelease, like 1.3.0-beta.1, is only offered on a channel
+// that includes it). os and arch use the
+// GOOS and GOARCH names, and an arch of "all" matches any architecture. a url
+// that isn't absolute is resolved relative to the manifest url. sha256 is the
+// hex encoded digest of the file at url, and each url must point to a tgz
+// archive that contains the binary.
+package manifest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"runtime"
+	"time"
+
+	"github.com/Masterminds/semver"
+	"github.com/pkg/errors"
+	"github.com/usrbinapp/usrbin-go/pkg/release"
+	"github.com/usrbinapp/usrbin-go/pkg/retry"
+	"github.com/usrbinapp/usrbin-go/pkg/updatechecker"
+)
+
+const (
+	SchemaVersion = 1
+)
+
+var (
+	ErrReleaseNotFound          = errors.New("release not found")
+	ErrMissingChecksum          = errors.New("missing checksum")
+	ErrUnsupportedSchemaVersion = errors.New("unsupported schema version")
+	ErrNoMatchingArchitectures  = release.ErrNoMatchingArchitectures
+	ErrTimeoutExceeded          = release.ErrTimeoutExceeded
+	ErrChecksumMismatch         = release.ErrChecksumMismatch
+)
+
+// Manifest is the document that's served at the manifest url
+type Manifest struct {
+	SchemaVersion int       `json:"schemaVersion"`
+	Versions      []Version `json:"versions"`
+}
+
+// Version is a single release in the manifest
+type Version struct {
+	Version    string     `json:"version"`
+	ReleasedAt *time.Time `json:"releasedAt"`
+
+	Assets []Asset `json:"assets"`
+}
+
+func (v Version) versionInfo() updatechecker.VersionInfo {
+	return updatechecker.VersionInfo{
+		Version:    v.Version,
+		ReleasedAt: v.ReleasedAt,
+	}
+}
+
+// Asset is a single downloadable archive for one os and arch
+type Asset struct {
+	OS     string `json:"os"`
+	Arch   string `json:"arch"`
+	URL    string `json:"url"`
+	SHA256 string `json:"sha256"`
+}
+
+type ManifestUpdateChecker struct {
+	timeout time.Duration
+
+	manifestURL string
+
+	httpClient  *http.Client
+	retryPolicy retry.Policy
+
+	channel           string
+	versionConstraint *semver.Constraints
+}
+
+var _ updatechecker.UpdateChecker = (*ManifestUpdateChecker)(nil)
+var _ updatechecker.ContextUpdateChecker = (*ManifestUpdateChecker)(nil)
+var _ updatechecker.VersionLister = (*ManifestUpdateChecker)(nil)
+
+// Option is a functional option for configuring the update checker
+type Option func(*ManifestUpdateChecker)
+
+// WithHTTPClient will make all requests, including downloads, with the
+// http client passed in instead of http.DefaultClient
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *ManifestUpdateChecker) {
+		if httpClient != nil {
+			c.httpClient = httpClient
+		}
+	}
+}
+
+// WithRetryPolicy will set how failed requests are retried, instead of
+// retry.DefaultPolicy
+func WithRetryPolicy(policy retry.Policy) Option {
+	return func(c *ManifestUpdateChecker) {
+		c.retryPolicy = policy
+	}
+}
+
+// WithChannel will offer the versions in the channel (stable, rc, beta,
+// alpha, nightly or any other name). see updatechecker.InChannel
+func WithChannel(channel string) Option {
+	return func(c *ManifestUpdateChecker) {
+		c.channel = channel
+	}
+}
+
+// WithVersionConstraint will only offer the versions that satisfy
+// constraint. see updatechecker.SatisfiesConstraint
+func WithVersionConstraint(constraint *semver.Constraints) Option {
+	return func(c *ManifestUpdateChecker) {
+		c.versionConstraint = constraint
+	}
+}
+
+// NewManifestUpdateChecker will return an update checker that reads
+// the manifest at manifestURL
+func NewManifestUpdateChecker(manifestURL string, opts ...Option) updatechecker.UpdateChecker {
+	if _, err := url.Parse(manifestURL); err != nil {
+		panic(fmt.Sprintf("invalid manifest url: %s", manifestURL))
+	}
+
+	c := ManifestUpdateChecker{
+		manifestURL: manifestURL,
+		timeout:     time.Second * 3, // a default
+		httpClient:  http.DefaultClient,
+		retryPolicy: retry.DefaultPolicy,
+	}
+
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	c.httpClient = retry.NewClient(c.httpClient, c.retryPolicy)
+
+	return c
+}
+
+// DownloadVersion will download and extract the specific version, returning
+// a path to the extracted file in the archive
+// it's the responsibility of the caller to clean up the extracted file
+func (c ManifestUpdateChecker) DownloadVersion(version string, requireChecksumMatch bool) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	return c.DownloadVersionContext(ctx, version, requireChecksumMatch)
+}
+
+// DownloadVersionContext is DownloadVersion, stopping when ctx is done
+func (c ManifestUpdateChecker) DownloadVersionContext(ctx context.Context, version string, requireChecksumMatch bool) (string, error) {
+	manifest, err := getManifest(ctx, c.httpClient, c.manifestURL)
+	if err != nil {
+		return "", errors.Wrap(err, "get manifest")
+	}
+
+	v := manifest.findVersion(version)
+	if v == nil {
+		return "", ErrReleaseNotFound
+	}
+
+	asset, err := bestAsset(v.Assets, runtime.GOOS, runtime.GOARCH)
+	if err != nil {
+		return "", errors.Wrap(err, "best asset")
+	}
+
+	if asset.SHA256 == "" && requireChecksumMatch {
+		return "", ErrMissingChecksum
+	}
+
+	assetURL, err := resolveURL(c.manifestURL, asset.URL)
+	if err != nil {
+		return "", errors.Wrap(err, "resolve asset url")
+	}
+
+	fileInArchivePath, err := release.DownloadWithChecksum(ctx, c.httpClient, release.Asset{URL: assetURL}, asset.SHA256)
+	if err != nil {
+		return "", errors.Wrap(err, "download with checksum")
+	}
+
+	return fileInArchivePath, nil
+}
+
+// GetLatestVersion will return the highest version listed in the manifest
+func (c ManifestUpdateChecker) GetLatestVersion(timeout time.Duration) (*updatechecker.VersionInfo, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return c.GetLatestVersionContext(ctx)
+}
+
+// GetLatestVersionContext is GetLatestVersion, stopping when ctx is done
+func (c ManifestUpdateChecker) GetLatestVersionContext(ctx context.Context) (*updatechecker.VersionInfo, error) {
+	manifest, err := getManifest(ctx, c.httpClient, c.manifestURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "get manifest")
+	}
+
+	latest := manifest.latestVersion(c.channel, c.versionConstraint)
+	if latest == nil {
+		return nil, ErrReleaseNotFound
+	}
+
+	latestVersion := latest.versionInfo()
+	return &latestVersion, nil
+}
+
+// latestVersion will return the version in channel, that satisfies constraint,
+// with the highest semver, ignoring any that can't be parsed
+func (m Manifest) latestVersion(channel string, constraint *semver.Constraints) *Version {
+	var latestSemver *semver.Version
+	var latest *Version
+	for i, v := range m.Versions {
+		if !offers(v.Version, channel, constraint) {
+			continue
+		}
+
+		parsed, err := semver.NewVersion(v.Version)
+		if err != nil {
+			continue
+		}
+
+		if latestSemver == nil || parsed.GreaterThan(latestSemver) {
+			latestSemver = parsed
+			latest = &m.Versions[i]
+		}
+	}
+
+	return latest
+}
+
+// ListVersions will return every version in the manifest that's in the
+// channel and satisfies the constraint
+func (c ManifestUpdateChecker) ListVersions(timeout time.Duration) ([]updatechecker.VersionInfo, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return c.ListVersionsContext(ctx)
+}
+
+// ListVersionsContext is ListVersions, stopping when ctx is done
+func (c ManifestUpdateChecker) ListVersionsContext(ctx context.Context) ([]updatechecker.VersionInfo, error) {
+	manifest, err := getManifest(ctx, c.httpClient, c.manifestURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "get manifest")
+	}
+
+	versions := []updatechecker.VersionInfo{}
+	for _, v := range manifest.Versions {
+		if offers(v.Version, c.channel, c.versionConstraint) {
+			versions = append(versions, v.versionInfo())
+		}
+	}
+
+	updatechecker.SortVersions(versions)
+	return versions, nil
+}
+
+// offers will return true when version is in channel and satisfies constraint
+func offers(version string, channel string, constraint *semver.Constraints) bool {
+	return updatechecker.InChannel(version, false, channel) && updatechecker.SatisfiesConstraint(version, constraint)
+}
+
+func (m Manifest) findVersion(version string) *Version {
+	for i, v := range m.Versions {
+		if v.Version == version {
+			return &m.Versions[i]
+		}
+	}
+
+	// allow "v1.2.0" to match "1.2.0" and the other way around
+	wanted, err := semver.NewVersion(version)
+	if err != nil {
+		return nil
+	}
+	for i, v := range m.Versions {
+		parsed, err := semver.NewVersion(v.Version)
+		if err != nil {
+			continue
+		}
+
+		if parsed.Equal(wanted) {
+			return &m.Versions[i]
+		}
+	}
+
+	return nil
+}
+
+// bestAsset will return the asset for the os and arch provided, falling
+// back to an asset for the os with "all" for the arch
+func bestAsset(assets []Asset, goos string, goarch string) (*Asset, error) {
+	for _, asset := range assets {
+		if asset.OS == goos && asset.Arch == goarch {
+			return &asset, nil
+		}
+	}
+
+	for _, asset := range assets {
+		if asset.OS == goos && asset.Arch == "all" {
+			return &asset, nil
+		}
+	}
+
+	return nil, ErrNoMatchingArchitectures
+}
+
+func resolveURL(manifestURL string, assetURL string) (string, error) {
+	base, err := url.Parse(manifestURL)
+	if err != nil {
+		return "", errors.Wrap(err, "parse manifest url")
+	}
+
+	ref, err := url.Parse(assetURL)
+	if err != nil {
+		return "", errors.Wrap(err, "parse asset url")
+	}
+
+	return base.ResolveReference(ref).String(), nil
+}
+
+func getManifest(ctx context.Context, httpClient *http.Client, manifestURL string) (*Manifest, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", manifestURL, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "new request")
+	}
+
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		if release.TimeoutError(err) == ErrTimeoutExceeded {
+			return nil, ErrTimeoutExceeded
+		}
+		return nil, errors.Wrap(err, "do request")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, ErrReleaseNotFound
+		}
+
+		return nil, errors.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	manifest := Manifest{}
+	if err := json.NewDecoder(resp.Body).Decode(&manifest); err != nil {
+		return nil, errors.Wrap(err, "decode manifest")
+	}
+
+	if manifest.SchemaVersion != SchemaVersion {
+		return nil, errors.Wrapf(ErrUnsupportedSchemaVersion, "schema version %d", manifest.SchemaVersion)
+	}
+
+	return &manifest, nil
+}
@@ -0,0 +1,193 @@
+package manifest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/usrbinapp/usrbin-go/internal/archivetest"
+	"github.com/usrbinapp/usrbin-go/pkg/release"
+)
+
+func Test_GetLatestVersion(t *testing.T) {
+	tests := []struct {
+		name        string
+		manifest    string
+		wantVersion string
+		wantErr     error
+	}{
+		{
+			name: "highest semver wins",
+			manifest: `{
+				"schemaVersion": 1,
+				"versions": [
+					{"version": "1.10.0", "releasedAt": "2023-02-01T00:00:00Z"},
+					{"version": "not-a-version"},
+					{"version": "1.9.0", "releasedAt": "2023-01-01T00:00:00Z"}
+				]
+			}`,
+			wantVersion: "1.10.0",
+		},
+		{
+			name: "digest isn't a manifest field",
+			manifest: `{
+				"schemaVersion": 1,
+				"versions": [
+					{"version": "1.10.0", "releasedAt": "2023-02-01T00:00:00Z", "digest": "sha256:abc"}
+				]
+			}`,
+			wantVersion: "1.10.0",
+		},
+		{
+			name:     "no versions",
+			manifest: `{"schemaVersion": 1, "versions": []}`,
+			wantErr:  ErrReleaseNotFound,
+		},
+		{
+			name:     "unknown schema",
+			manifest: `{"schemaVersion": 2, "versions": []}`,
+			wantErr:  ErrUnsupportedSchemaVersion,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := require.New(t)
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, tt.manifest)
+			}))
+			defer server.Close()
+
+			c := NewManifestUpdateChecker(server.URL + "/cli/manifest.json")
+			got, err := c.GetLatestVersion(time.Second)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			req.NoError(err)
+			assert.Equal(t, tt.wantVersion, got.Version)
+			assert.Equal(t, "", got.Digest)
+			assert.Equal(t, time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC), got.ReleasedAt.UTC())
+		})
+	}
+}
+
+func Test_DownloadVersion(t *testing.T) {
+	req := require.New(t)
+
+	archiveContents, archiveChecksum, err := archivetest.CreateTGZWithExecutable([]byte("new version"))
+	req.NoError(err)
+
+	tests := []struct {
+		name                 string
+		checksum             string
+		requireChecksumMatch bool
+		wantErr              error
+	}{
+		{
+			name:                 "checksum matches",
+			checksum:             archiveChecksum,
+			requireChecksumMatch: true,
+		},
+		{
+			name:                 "checksum mismatch",
+			checksum:             "0000000000000000000000000000000000000000000000000000000000000000",
+			requireChecksumMatch: true,
+			wantErr:              ErrChecksumMismatch,
+		},
+		{
+			name:                 "missing checksum",
+			requireChecksumMatch: true,
+			wantErr:              ErrMissingChecksum,
+		},
+		{
+			name:                 "missing checksum, not required",
+			requireChecksumMatch: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := require.New(t)
+
+			mux := http.NewServeMux()
+			server := httptest.NewServer(mux)
+			defer server.Close()
+
+			mux.HandleFunc("/cli/manifest.json", func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintf(w, `{
+					"schemaVersion": 1,
+					"versions": [
+						{
+							"version": "1.0.0",
+							"assets": [
+								{"os": "other", "arch": "other", "url": "other.tar.gz"},
+								{"os": "%s", "arch": "%s", "url": "1.0.0/cli.tar.gz", "sha256": "%s"}
+							]
+						}
+					]
+				}`, runtime.GOOS, runtime.GOARCH, tt.checksum)
+			})
+			mux.HandleFunc("/cli/1.0.0/cli.tar.gz", func(w http.ResponseWriter, r *http.Request) {
+				w.Write(archiveContents)
+			})
+
+			c := NewManifestUpdateChecker(server.URL + "/cli/manifest.json")
+			got, err := c.DownloadVersion("v1.0.0", tt.requireChecksumMatch)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			req.NoError(err)
+			defer os.Remove(got)
+
+			contents, err := ioutil.ReadFile(got)
+			req.NoError(err)
+			assert.Equal(t, "new version", string(contents))
+		})
+	}
+}
+
+func Test_DownloadVersionFileURL(t *testing.T) {
+	req := require.New(t)
+
+	dir, err := ioutil.TempDir("", "usrbin")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+
+	archiveContents, _, err := archivetest.CreateTGZWithExecutable([]byte("local file"))
+	req.NoError(err)
+
+	archivePath := filepath.Join(dir, "cli.tar.gz")
+	req.NoError(ioutil.WriteFile(archivePath, archiveContents, 0644))
+
+	// a remote manifest can't point at a file on the local filesystem
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{
+			"schemaVersion": 1,
+			"versions": [
+				{
+					"version": "1.0.0",
+					"assets": [
+						{"os": "%s", "arch": "%s", "url": "%s"}
+					]
+				}
+			]
+		}`, runtime.GOOS, runtime.GOARCH, release.FileURL(archivePath))
+	}))
+	defer server.Close()
+
+	c := NewManifestUpdateChecker(server.URL + "/cli/manifest.json")
+	_, err = c.DownloadVersion("v1.0.0", false)
+	req.Error(err)
+	assert.Contains(t, err.Error(), "unsupported url scheme")
+}
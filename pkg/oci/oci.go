@@ -2,122 +2,417 @@ package oci
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net/http"
 	"os"
-	"path/filepath"
+	"regexp"
+	"runtime"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/Masterminds/semver"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/pkg/errors"
+	"github.com/usrbinapp/usrbin-go/pkg/archive"
+	"github.com/usrbinapp/usrbin-go/pkg/release"
+	"github.com/usrbinapp/usrbin-go/pkg/retry"
 	"github.com/usrbinapp/usrbin-go/pkg/updatechecker"
-	"oras.land/oras-go/v2"
-	"oras.land/oras-go/v2/content/file"
+	"oras.land/oras-go/v2/content"
 	"oras.land/oras-go/v2/registry"
 	"oras.land/oras-go/v2/registry/remote"
+	"oras.land/oras-go/v2/registry/remote/auth"
+	"oras.land/oras-go/v2/registry/remote/credentials"
+)
+
+const (
+	// AnnotationPlatform can be set on a layer to the "os/arch" that
+	// the file in the layer is built for
+	AnnotationPlatform = "app.usrbin.platform"
+
+	mediaTypeDockerManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+	mediaTypeDockerImageConfig  = "application/vnd.docker.container.image.v1+json"
+)
+
+var (
+	ErrReleaseNotFound         = errors.New("release not found")
+	ErrNoAssets                = release.ErrNoAssets
+	ErrNoMatchingArchitectures = release.ErrNoMatchingArchitectures
+	ErrChecksumMismatch        = release.ErrChecksumMismatch
+	ErrTimeoutExceeded         = release.ErrTimeoutExceeded
 )
 
 type OCIUpdateChecker struct {
+	timeout time.Duration
+
 	artifact string
+
+	// credential is set when credentials are passed in explicitly, otherwise
+	// the docker config (and any credential helpers it names) is used
+	credential *auth.Credential
+
+	dockerConfigPath string
+
+	// pinnedDigests maps a tag to the manifest digest that it resolved to
+	// in GetLatestVersion, so that DownloadVersion pulls the same content
+	// even if the tag is moved in between
+	pinnedDigests *sync.Map
+
+	plainHTTP             bool
+	insecureSkipTLSVerify bool
+	caBundlePath          string
+
+	// client is set when an http client is passed in, otherwise one is
+	// built from the tls options
+	client *http.Client
+
+	retryPolicy retry.Policy
+
+	channel           string
+	channelTagPattern map[string]*regexp.Regexp
+	versionConstraint *semver.Constraints
 }
 
 var _ updatechecker.UpdateChecker = (*OCIUpdateChecker)(nil)
+var _ updatechecker.ContextUpdateChecker = (*OCIUpdateChecker)(nil)
+var _ updatechecker.VersionLister = (*OCIUpdateChecker)(nil)
+var _ updatechecker.DigestPinner = (*OCIUpdateChecker)(nil)
+
+// Option is a functional option for configuring the update checker
+type Option func(*OCIUpdateChecker)
 
-func NewOCIUpdateChecker(artifact string) updatechecker.UpdateChecker {
-	return &OCIUpdateChecker{
-		artifact: strings.TrimRight(artifact, ":"),
+// WithBasicAuth will authenticate to the registry with the username
+// and password passed in
+func WithBasicAuth(username string, password string) Option {
+	return func(c *OCIUpdateChecker) {
+		c.credential = &auth.Credential{
+			Username: username,
+			Password: password,
+		}
 	}
 }
 
-// DownloadVersion will download and extract the specific version, returning
-// a path to the extracted file in the archive
-// it's the responsibility of the caller to clean up the extracted file
-func (c OCIUpdateChecker) DownloadVersion(version string, requireChecksumMatch bool) (string, error) {
-	ref := fmt.Sprintf("%s:%s", c.artifact, version)
+// WithToken will authenticate to the registry with the bearer (registry)
+// token passed in
+func WithToken(token string) Option {
+	return func(c *OCIUpdateChecker) {
+		c.credential = &auth.Credential{
+			AccessToken: token,
+		}
+	}
+}
 
-	tmpDir, err := ioutil.TempDir("", "usrbin")
-	if err != nil {
-		return "", errors.Wrap(err, "create temp dir")
+// WithDockerConfig will read credentials from the docker config file at path
+// instead of the default location ($DOCKER_CONFIG/config.json or
+// ~/.docker/config.json)
+func WithDockerConfig(path string) Option {
+	return func(c *OCIUpdateChecker) {
+		c.dockerConfigPath = path
 	}
-	// defer os.RemoveAll(tmpDir)
+}
 
-	// Pull file(s) from registry and save to disk
-	fileStore, err := file.New(tmpDir)
-	if err != nil {
-		return "", errors.Wrap(err, "create file store")
+// WithPlainHTTP will connect to the registry over http instead of https
+func WithPlainHTTP() Option {
+	return func(c *OCIUpdateChecker) {
+		c.plainHTTP = true
+	}
+}
+
+// WithInsecureSkipTLSVerify will connect to the registry without verifying
+// its tls certificate
+func WithInsecureSkipTLSVerify() Option {
+	return func(c *OCIUpdateChecker) {
+		c.insecureSkipTLSVerify = true
+	}
+}
+
+// WithCABundle will trust the pem encoded certificates in the file at path,
+// in addition to the system roots, when verifying the registry's certificate
+func WithCABundle(path string) Option {
+	return func(c *OCIUpdateChecker) {
+		c.caBundlePath = path
 	}
+}
 
-	defer fileStore.Close()
+// WithTimeout will set the deadline for DownloadVersion. GetLatestVersion
+// uses the timeout that's passed to it, and the Context variants use
+// the deadline of the context
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *OCIUpdateChecker) {
+		c.timeout = timeout
+	}
+}
 
-	src, err := remote.NewRepository(c.artifact)
+// WithHTTPClient will make all requests to the registry with the http
+// client passed in. WithInsecureSkipTLSVerify and WithCABundle are
+// ignored, the client's transport is responsible for tls
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *OCIUpdateChecker) {
+		c.client = httpClient
+	}
+}
+
+// WithRetryPolicy will set how failed requests are retried, instead of
+// retry.DefaultPolicy
+func WithRetryPolicy(policy retry.Policy) Option {
+	return func(c *OCIUpdateChecker) {
+		c.retryPolicy = policy
+	}
+}
+
+// WithChannel will offer the tags in the channel (stable, rc, beta, alpha,
+// nightly or any other name). see updatechecker.InChannel and
+// WithChannelTagPattern
+func WithChannel(channel string) Option {
+	return func(c *OCIUpdateChecker) {
+		c.channel = channel
+	}
+}
+
+// WithChannelTagPattern will offer the tags that match pattern, instead of
+// using their semver prerelease, when channel is the channel. tags still
+// have to be semver to be compared
+func WithChannelTagPattern(channel string, pattern *regexp.Regexp) Option {
+	return func(c *OCIUpdateChecker) {
+		if pattern == nil {
+			return
+		}
+
+		if c.channelTagPattern == nil {
+			c.channelTagPattern = map[string]*regexp.Regexp{}
+		}
+		c.channelTagPattern[channel] = pattern
+	}
+}
+
+// WithVersionConstraint will only offer the tags that satisfy
+// constraint. see updatechecker.SatisfiesConstraint
+func WithVersionConstraint(constraint *semver.Constraints) Option {
+	return func(c *OCIUpdateChecker) {
+		c.versionConstraint = constraint
+	}
+}
+
+// NewOCIUpdateChecker will return an update checker for the artifact passed in.
+// by default, credentials are read from the docker config file, including
+// any credential helpers (credsStore and credHelpers) that it configures
+func NewOCIUpdateChecker(artifact string, opts ...Option) updatechecker.UpdateChecker {
+	c := &OCIUpdateChecker{
+		artifact:      strings.TrimRight(artifact, ":"),
+		pinnedDigests: &sync.Map{},
+		timeout:       time.Second * 3, // a default
+		retryPolicy:   retry.DefaultPolicy,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// newRepository will return the remote repository for the artifact, with
+// an auth client that uses the configured credentials
+func (c OCIUpdateChecker) newRepository() (*remote.Repository, error) {
+	repo, err := remote.NewRepository(c.artifact)
 	if err != nil {
-		return "", errors.Wrap(err, "create remote repository")
+		return nil, errors.Wrap(err, "new repository")
 	}
 
-	copyOpts := oras.DefaultCopyOptions
-	copyOpts.Concurrency = 1
+	credential, err := c.credentialFunc(repo.Reference.Registry)
+	if err != nil {
+		return nil, errors.Wrap(err, "credential")
+	}
 
-	localTarget := oras.Target(fileStore)
-	_, err = oras.Copy(context.Background(), src, ref, localTarget, ref, copyOpts)
+	httpClient, err := c.httpClient()
 	if err != nil {
-		return "", errors.Wrap(err, "copy from remote")
+		return nil, errors.Wrap(err, "http client")
 	}
 
-	path, err := bestAsset(tmpDir)
+	repo.PlainHTTP = c.plainHTTP
+	repo.Client = &auth.Client{
+		Client:     httpClient,
+		Cache:      auth.NewCache(),
+		Credential: credential,
+	}
+
+	return repo, nil
+}
+
+func (c OCIUpdateChecker) httpClient() (*http.Client, error) {
+	if c.client != nil {
+		return retry.NewClient(c.client, c.retryPolicy), nil
+	}
+
+	if !c.insecureSkipTLSVerify && c.caBundlePath == "" {
+		return retry.NewClient(http.DefaultClient, c.retryPolicy), nil
+	}
+
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: c.insecureSkipTLSVerify,
+	}
+
+	if c.caBundlePath != "" {
+		caBundle, err := ioutil.ReadFile(c.caBundlePath)
+		if err != nil {
+			return nil, errors.Wrap(err, "read ca bundle")
+		}
+
+		rootCAs, err := x509.SystemCertPool()
+		if err != nil {
+			rootCAs = x509.NewCertPool()
+		}
+
+		if !rootCAs.AppendCertsFromPEM(caBundle) {
+			return nil, errors.Errorf("no certificates found in %s", c.caBundlePath)
+		}
+
+		tlsConfig.RootCAs = rootCAs
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	return retry.NewClient(&http.Client{Transport: transport}, c.retryPolicy), nil
+}
+
+// credentialFunc will return the credentials for registry. when none were
+// passed in and the default docker config can't be loaded, such as when
+// $HOME isn't set or config.json is malformed, nil is returned so that the
+// registry is accessed anonymously
+func (c OCIUpdateChecker) credentialFunc(registry string) (auth.CredentialFunc, error) {
+	if c.credential != nil {
+		return auth.StaticCredential(registry, *c.credential), nil
+	}
+
+	if c.dockerConfigPath != "" {
+		store, err := credentials.NewStore(c.dockerConfigPath, credentials.StoreOptions{})
+		if err != nil {
+			return nil, errors.Wrap(err, "load docker config")
+		}
+
+		return credentials.Credential(store), nil
+	}
+
+	store, err := credentials.NewStoreFromDocker(credentials.StoreOptions{})
 	if err != nil {
-		return "", errors.Wrap(err, "get best asset")
+		return nil, nil
 	}
 
-	if path == "" {
-		return "", errors.New("no assets found")
+	return credentials.Credential(store), nil
+}
+
+// PinsDigests will return true, since DownloadVersion accepts a tag pinned
+// to the digest that GetLatestVersion reported
+func (c OCIUpdateChecker) PinsDigests() bool {
+	return true
+}
+
+// DownloadVersion will download the layer for the current platform from the
+// specific version, returning a path to the binary
+// version can be a tag, a digest, or a tag pinned to a digest (v1.2.0@sha256:...).
+// a tag that was returned by GetLatestVersion is pulled by the digest that it
+// resolved to at that time
+// when requireChecksumMatch is set, the layer is verified against its digest
+// it's the responsibility of the caller to clean up the file
+func (c OCIUpdateChecker) DownloadVersion(version string, requireChecksumMatch bool) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	return c.DownloadVersionContext(ctx, version, requireChecksumMatch)
+}
+
+// DownloadVersionContext is DownloadVersion, stopping when ctx is done
+func (c OCIUpdateChecker) DownloadVersionContext(ctx context.Context, version string, requireChecksumMatch bool) (string, error) {
+	path, err := c.downloadVersion(ctx, version, requireChecksumMatch)
+	if err != nil {
+		return "", release.TimeoutError(err)
 	}
 
-	// make the file executable
-	err = os.Chmod(path, 0755)
+	return path, nil
+}
+
+func (c OCIUpdateChecker) downloadVersion(ctx context.Context, version string, requireChecksumMatch bool) (string, error) {
+	repo, err := c.newRepository()
 	if err != nil {
-		return "", errors.Wrap(err, "chmod")
+		return "", errors.Wrap(err, "create remote repository")
 	}
 
-	// copy the best asset to a temp file
-	tmpFile, err := ioutil.TempFile("", "usrbin")
+	manifest, err := fetchPlatformManifest(ctx, repo, c.reference(version), runtime.GOOS, runtime.GOARCH)
 	if err != nil {
-		return "", errors.Wrap(err, "create temp file")
+		return "", errors.Wrap(err, "fetch manifest")
 	}
-	defer tmpFile.Close()
 
-	// copy the file
-	asset, err := os.Open(path)
+	layer, err := bestLayer(manifest.Layers, runtime.GOOS, runtime.GOARCH)
 	if err != nil {
-		return "", errors.Wrap(err, "open asset")
+		return "", errors.Wrap(err, "best layer")
 	}
 
-	_, err = io.Copy(tmpFile, asset)
+	path, err := downloadLayer(ctx, repo, *layer, requireChecksumMatch)
 	if err != nil {
-		return "", errors.Wrap(err, "copy asset")
+		return "", errors.Wrap(err, "download layer")
 	}
 
-	return tmpFile.Name(), nil
+	return path, nil
+}
 
+// reference will return the reference to pull for version, preferring
+// a digest over a tag whenever one is known
+func (c OCIUpdateChecker) reference(version string) string {
+	if i := strings.Index(version, "@"); i != -1 {
+		return version[i+1:]
+	}
+
+	if c.pinnedDigests != nil {
+		if pinned, ok := c.pinnedDigests.Load(version); ok {
+			return pinned.(string)
+		}
+	}
+
+	return version
 }
 
 // GetLatestVersion will return the latest version information from the oci repository
 func (c OCIUpdateChecker) GetLatestVersion(timeout time.Duration) (*updatechecker.VersionInfo, error) {
-	repo, err := remote.NewRepository(c.artifact)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return c.GetLatestVersionContext(ctx)
+}
+
+// GetLatestVersionContext is GetLatestVersion, stopping when ctx is done
+func (c OCIUpdateChecker) GetLatestVersionContext(ctx context.Context) (*updatechecker.VersionInfo, error) {
+	latestVersion, err := c.getLatestVersion(ctx)
 	if err != nil {
-		return nil, err
+		return nil, release.TimeoutError(err)
+	}
+
+	return latestVersion, nil
+}
+
+func (c OCIUpdateChecker) getLatestVersion(ctx context.Context) (*updatechecker.VersionInfo, error) {
+	repo, err := c.newRepository()
+	if err != nil {
+		return nil, errors.Wrap(err, "create remote repository")
 	}
 
-	ctx := context.Background()
 	tags, err := registry.Tags(ctx, repo)
 	if err != nil {
-		return nil, err
+		return nil, errors.Wrap(err, "list tags")
 	}
 
 	var latestSemver *semver.Version
 	var latestUnparsed string
 	for _, tag := range tags {
+		if !c.offers(tag) {
+			continue
+		}
+
 		parsed, err := semver.NewVersion(tag)
 		if err != nil {
 			continue
@@ -129,34 +424,359 @@ func (c OCIUpdateChecker) GetLatestVersion(timeout time.Duration) (*updatechecke
 		}
 	}
 
+	if latestUnparsed == "" {
+		return nil, ErrReleaseNotFound
+	}
+
+	desc, err := repo.Resolve(ctx, latestUnparsed)
+	if err != nil {
+		return nil, errors.Wrap(err, "resolve tag")
+	}
+
+	if c.pinnedDigests != nil {
+		c.pinnedDigests.Store(latestUnparsed, desc.Digest.String())
+	}
+
+	// the released at time is only reported when it's available, so a
+	// missing or unreadable config doesn't fail the update check
+	releasedAt, err := getReleasedAt(ctx, repo, desc.Digest.String())
+	if err != nil {
+		releasedAt = nil
+	}
+
 	latestVersion := &updatechecker.VersionInfo{
 		Version:    latestUnparsed,
-		ReleasedAt: nil,
+		Digest:     desc.Digest.String(),
+		ReleasedAt: releasedAt,
 	}
 
 	return latestVersion, nil
 }
 
-func bestAsset(inPath string) (string, error) {
-	bestAssetPath := ""
-	if err := filepath.Walk(inPath, func(path string, info os.FileInfo, err error) error {
+// fetchPlatformManifest will fetch the image manifest for reference. when
+// reference is an image index, the manifest for the os and arch is returned
+func fetchPlatformManifest(ctx context.Context, repo *remote.Repository, reference string, goos string, goarch string) (*ocispec.Manifest, error) {
+	desc, b, err := fetchManifestContent(ctx, repo, reference)
+	if err != nil {
+		return nil, err
+	}
+
+	if isIndex(desc) {
+		index := ocispec.Index{}
+		if err := json.Unmarshal(b, &index); err != nil {
+			return nil, errors.Wrap(err, "unmarshal index")
+		}
+
+		manifestDesc, err := platformManifest(index.Manifests, goos, goarch)
+		if err != nil {
+			return nil, err
+		}
+
+		b, err = content.FetchAll(ctx, repo, *manifestDesc)
+		if err != nil {
+			return nil, errors.Wrap(err, "fetch platform manifest")
+		}
+	}
+
+	manifest := ocispec.Manifest{}
+	if err := json.Unmarshal(b, &manifest); err != nil {
+		return nil, errors.Wrap(err, "unmarshal manifest")
+	}
+
+	return &manifest, nil
+}
+
+// ListVersions will return every semver tag in the channel that satisfies
+// the constraint. only the tag is listed, finding when each one was
+// released would take a request per tag
+func (c OCIUpdateChecker) ListVersions(timeout time.Duration) ([]updatechecker.VersionInfo, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return c.ListVersionsContext(ctx)
+}
+
+// ListVersionsContext is ListVersions, stopping when ctx is done
+func (c OCIUpdateChecker) ListVersionsContext(ctx context.Context) ([]updatechecker.VersionInfo, error) {
+	versions, err := c.listVersions(ctx)
+	if err != nil {
+		return nil, release.TimeoutError(err)
+	}
+
+	return versions, nil
+}
+
+func (c OCIUpdateChecker) listVersions(ctx context.Context) ([]updatechecker.VersionInfo, error) {
+	repo, err := c.newRepository()
+	if err != nil {
+		return nil, errors.Wrap(err, "create remote repository")
+	}
+
+	tags, err := registry.Tags(ctx, repo)
+	if err != nil {
+		return nil, errors.Wrap(err, "list tags")
+	}
+
+	versions := []updatechecker.VersionInfo{}
+	for _, tag := range tags {
+		if !c.offers(tag) {
+			continue
+		}
+
+		if _, err := semver.NewVersion(tag); err != nil {
+			continue
+		}
+
+		versions = append(versions, updatechecker.VersionInfo{
+			Version: tag,
+		})
+	}
+
+	updatechecker.SortVersions(versions)
+	return versions, nil
+}
+
+// offers will return true when the tag is in the channel and satisfies
+// the constraint
+func (c OCIUpdateChecker) offers(tag string) bool {
+	return c.inChannel(tag) && updatechecker.SatisfiesConstraint(tag, c.versionConstraint)
+}
+
+// inChannel will return true when the tag is offered on the channel
+func (c OCIUpdateChecker) inChannel(tag string) bool {
+	channel := c.channel
+	if updatechecker.IsStableChannel(channel) {
+		channel = updatechecker.ChannelStable
+	}
+
+	if pattern, ok := c.channelTagPattern[channel]; ok {
+		return pattern.MatchString(tag)
+	}
+
+	return updatechecker.InChannel(tag, false, channel)
+}
+
+// fetchManifestContent will fetch and verify the manifest (or index) for reference
+func fetchManifestContent(ctx context.Context, repo *remote.Repository, reference string) (ocispec.Descriptor, []byte, error) {
+	desc, rc, err := repo.FetchReference(ctx, reference)
+	if err != nil {
+		return ocispec.Descriptor{}, nil, errors.Wrap(err, "fetch reference")
+	}
+	defer rc.Close()
+
+	b, err := content.ReadAll(rc, desc)
+	if err != nil {
+		return ocispec.Descriptor{}, nil, errors.Wrap(err, "read manifest")
+	}
+
+	return desc, b, nil
+}
+
+// getReleasedAt will return the time that reference was created. this is read
+// from the org.opencontainers.image.created annotation on the manifest (or
+// index), falling back to the created time in the image config. nil is
+// returned when there is no created time
+func getReleasedAt(ctx context.Context, repo *remote.Repository, reference string) (*time.Time, error) {
+	desc, b, err := fetchManifestContent(ctx, repo, reference)
+	if err != nil {
+		return nil, err
+	}
+
+	// the fields that are needed from both an index and a manifest
+	manifest := struct {
+		Annotations map[string]string    `json:"annotations"`
+		Config      ocispec.Descriptor   `json:"config"`
+		Manifests   []ocispec.Descriptor `json:"manifests"`
+	}{}
+	if err := json.Unmarshal(b, &manifest); err != nil {
+		return nil, errors.Wrap(err, "unmarshal manifest")
+	}
+
+	if created, err := time.Parse(time.RFC3339, manifest.Annotations[ocispec.AnnotationCreated]); err == nil {
+		return &created, nil
+	}
+
+	if isIndex(desc) {
+		if len(manifest.Manifests) == 0 {
+			return nil, nil
+		}
+
+		manifestDesc, err := platformManifest(manifest.Manifests, runtime.GOOS, runtime.GOARCH)
 		if err != nil {
-			return err
+			manifestDesc = &manifest.Manifests[0]
+		}
+
+		return getReleasedAt(ctx, repo, manifestDesc.Digest.String())
+	}
+
+	if manifest.Config.MediaType != ocispec.MediaTypeImageConfig && manifest.Config.MediaType != mediaTypeDockerImageConfig {
+		return nil, nil
+	}
+
+	b, err = content.FetchAll(ctx, repo, manifest.Config)
+	if err != nil {
+		return nil, errors.Wrap(err, "fetch config")
+	}
+
+	config := ocispec.Image{}
+	if err := json.Unmarshal(b, &config); err != nil {
+		return nil, errors.Wrap(err, "unmarshal config")
+	}
+
+	return config.Created, nil
+}
+
+func isIndex(desc ocispec.Descriptor) bool {
+	return desc.MediaType == ocispec.MediaTypeImageIndex || desc.MediaType == mediaTypeDockerManifestList
+}
+
+// platformManifest will return the manifest in an index for the os and arch
+func platformManifest(manifests []ocispec.Descriptor, goos string, goarch string) (*ocispec.Descriptor, error) {
+	for _, manifest := range manifests {
+		if manifest.Platform == nil {
+			continue
+		}
+
+		if manifest.Platform.OS == goos && manifest.Platform.Architecture == goarch {
+			return &manifest, nil
+		}
+	}
+
+	return nil, ErrNoMatchingArchitectures
+}
+
+// bestLayer will find the layer for the os and arch provided. a manifest with a
+// single layer that doesn't say which platform it's for, in a platform annotation
+// or its title, is assumed to be for this platform. otherwise the platform
+// annotation ("linux/amd64") is preferred, and then the same file naming
+// rules that are used for release assets are applied to the title annotation
+func bestLayer(layers []ocispec.Descriptor, goos string, goarch string) (*ocispec.Descriptor, error) {
+	if len(layers) == 0 {
+		return nil, ErrNoAssets
+	}
+
+	if len(layers) == 1 && !namesPlatform(layers[0]) {
+		return &layers[0], nil
+	}
+
+	platform := fmt.Sprintf("%s/%s", goos, goarch)
+	for _, layer := range layers {
+		if layer.Annotations[AnnotationPlatform] == platform {
+			return &layer, nil
+		}
+	}
+
+	assets := []release.Asset{}
+	for _, layer := range layers {
+		title := layer.Annotations[ocispec.AnnotationTitle]
+		if title == "" {
+			continue
+		}
+
+		assets = append(assets, release.Asset{
+			Name: title,
+		})
+	}
+
+	asset, err := release.BestAsset(assets, goos, goarch)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, layer := range layers {
+		if layer.Annotations[ocispec.AnnotationTitle] == asset.Name {
+			return &layer, nil
 		}
+	}
+
+	return nil, ErrNoMatchingArchitectures
+}
 
-		if info.IsDir() {
-			return nil
+// platformNames are the operating systems that a layer's title is checked
+// for, to tell if the layer is only for one platform
+var platformNames = []string{"darwin", "linux", "windows", "freebsd", "openbsd", "netbsd"}
+
+// namesPlatform will return true when the layer has a platform annotation,
+// or its title names an operating system
+func namesPlatform(layer ocispec.Descriptor) bool {
+	if layer.Annotations[AnnotationPlatform] != "" {
+		return true
+	}
+
+	title := strings.ToLower(layer.Annotations[ocispec.AnnotationTitle])
+	for _, name := range platformNames {
+		if strings.Contains(title, name) {
+			return true
 		}
+	}
+
+	return false
+}
+
+// downloadLayer will fetch the layer into a temp file, extracting it when
+// it's a tgz archive, and return the path to the executable
+func downloadLayer(ctx context.Context, repo *remote.Repository, layer ocispec.Descriptor, verify bool) (string, error) {
+	rc, err := repo.Fetch(ctx, layer)
+	if err != nil {
+		return "", errors.Wrap(err, "fetch layer")
+	}
+	defer rc.Close()
+
+	tmpFile, err := ioutil.TempFile("", "usrbin")
+	if err != nil {
+		return "", errors.Wrap(err, "create temp file")
+	}
+	defer tmpFile.Close()
 
-		if bestAssetPath != "" {
-			return nil
+	if verify {
+		vr := content.NewVerifyReader(rc, layer)
+		if _, err := io.Copy(tmpFile, vr); err != nil {
+			os.Remove(tmpFile.Name())
+			if errors.Is(err, content.ErrTrailingData) {
+				return "", ErrChecksumMismatch
+			}
+			return "", errors.Wrap(err, "copy layer")
 		}
 
-		bestAssetPath = path
-		return nil
-	}); err != nil {
-		return "", err
+		if err := vr.Verify(); err != nil {
+			os.Remove(tmpFile.Name())
+			if errors.Is(err, content.ErrMismatchedDigest) {
+				return "", ErrChecksumMismatch
+			}
+			return "", errors.Wrap(err, "verify layer")
+		}
+	} else {
+		if _, err := io.Copy(tmpFile, rc); err != nil {
+			os.Remove(tmpFile.Name())
+			return "", errors.Wrap(err, "copy layer")
+		}
+	}
+
+	if isArchive(layer) {
+		defer os.Remove(tmpFile.Name())
+
+		path, err := archive.FindProbableFileInWhatMightBeAnArchive(tmpFile.Name())
+		if err != nil {
+			return "", errors.Wrap(err, "find probable file")
+		}
+
+		return path, nil
+	}
+
+	// make the file executable
+	if err := os.Chmod(tmpFile.Name(), 0755); err != nil {
+		os.Remove(tmpFile.Name())
+		return "", errors.Wrap(err, "chmod")
+	}
+
+	return tmpFile.Name(), nil
+}
+
+func isArchive(layer ocispec.Descriptor) bool {
+	if strings.HasSuffix(layer.MediaType, "+gzip") || strings.HasSuffix(layer.MediaType, ".gzip") {
+		return true
 	}
 
-	return bestAssetPath, nil
+	title := strings.ToLower(layer.Annotations[ocispec.AnnotationTitle])
+	return strings.HasSuffix(title, ".tar.gz") || strings.HasSuffix(title, ".tgz")
 }
@@ -0,0 +1,653 @@
+package oci
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/usrbinapp/usrbin-go/pkg/updatechecker"
+	"oras.land/oras-go/v2/registry/remote/auth"
+)
+
+func Test_newRepositoryCredentials(t *testing.T) {
+	dir, err := ioutil.TempDir("", "usrbin")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	dockerConfigPath := filepath.Join(dir, "config.json")
+	dockerConfig := fmt.Sprintf(`{"auths": {"registry.example.com": {"auth": "%s"}}}`, base64.StdEncoding.EncodeToString([]byte("docker-user:docker-pass")))
+	require.NoError(t, ioutil.WriteFile(dockerConfigPath, []byte(dockerConfig), 0600))
+
+	tests := []struct {
+		name     string
+		artifact string
+		opts     []Option
+		want     auth.Credential
+	}{
+		{
+			name:     "docker config",
+			artifact: "registry.example.com/usrbinapp/cli",
+			opts:     []Option{WithDockerConfig(dockerConfigPath)},
+			want:     auth.Credential{Username: "docker-user", Password: "docker-pass"},
+		},
+		{
+			name:     "docker config, other registry",
+			artifact: "other.example.com/usrbinapp/cli",
+			opts:     []Option{WithDockerConfig(dockerConfigPath)},
+			want:     auth.EmptyCredential,
+		},
+		{
+			name:     "basic auth takes precedence",
+			artifact: "registry.example.com/usrbinapp/cli",
+			opts:     []Option{WithDockerConfig(dockerConfigPath), WithBasicAuth("user", "pass")},
+			want:     auth.Credential{Username: "user", Password: "pass"},
+		},
+		{
+			name:     "token",
+			artifact: "registry.example.com/usrbinapp/cli",
+			opts:     []Option{WithToken("token")},
+			want:     auth.Credential{AccessToken: "token"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := require.New(t)
+
+			c := NewOCIUpdateChecker(tt.artifact, tt.opts...).(*OCIUpdateChecker)
+			repo, err := c.newRepository()
+			req.NoError(err)
+
+			client, ok := repo.Client.(*auth.Client)
+			req.True(ok)
+
+			got, err := client.Credential(context.Background(), repo.Reference.Registry)
+			req.NoError(err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_GetLatestVersion(t *testing.T) {
+	plainRegistry := newTestRegistry(t, false)
+	tlsRegistry := newTestRegistry(t, true)
+
+	for _, registry := range []*testRegistry{plainRegistry, tlsRegistry} {
+		for _, tag := range []string{"v1.0.0", "v1.2.0", "latest", "v1.1.0"} {
+			registry.pushFiles(tag, map[string][]byte{"cli": []byte(tag)}, nil)
+		}
+	}
+
+	dir, err := ioutil.TempDir("", "usrbin")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	caBundlePath := filepath.Join(dir, "ca.pem")
+	caBundle := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: tlsRegistry.Certificate().Raw})
+	require.NoError(t, ioutil.WriteFile(caBundlePath, caBundle, 0644))
+
+	tests := []struct {
+		name     string
+		registry *testRegistry
+		opts     []Option
+		wantErr  bool
+	}{
+		{
+			name:     "plain http",
+			registry: plainRegistry,
+			opts:     []Option{WithPlainHTTP()},
+		},
+		{
+			name:     "plain http registry, without plain http",
+			registry: plainRegistry,
+			wantErr:  true,
+		},
+		{
+			name:     "custom ca",
+			registry: tlsRegistry,
+			opts:     []Option{WithCABundle(caBundlePath)},
+		},
+		{
+			name:     "skip tls verify",
+			registry: tlsRegistry,
+			opts:     []Option{WithInsecureSkipTLSVerify()},
+		},
+		{
+			name:     "untrusted certificate",
+			registry: tlsRegistry,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := require.New(t)
+
+			opts := append([]Option{WithDockerConfig(filepath.Join(dir, "config.json"))}, tt.opts...)
+			c := NewOCIUpdateChecker(fmt.Sprintf("%s/usrbinapp/cli", tt.registry.host()), opts...)
+			got, err := c.GetLatestVersion(time.Second)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			req.NoError(err)
+			assert.Equal(t, "v1.2.0", got.Version)
+		})
+	}
+}
+
+func Test_GetLatestVersionWithoutDockerConfig(t *testing.T) {
+	registry := newTestRegistry(t, false)
+	registry.pushFiles("v1.0.0", map[string][]byte{"cli": []byte("v1.0.0")}, nil)
+
+	// the default docker config can't be found without a home dir
+	t.Setenv("HOME", "")
+	t.Setenv("DOCKER_CONFIG", "")
+
+	c := NewOCIUpdateChecker(fmt.Sprintf("%s/usrbinapp/cli", registry.host()), WithPlainHTTP())
+	got, err := c.GetLatestVersion(time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, "v1.0.0", got.Version)
+}
+
+func Test_GetLatestVersionChannel(t *testing.T) {
+	registry := newTestRegistry(t, false)
+	for _, tag := range []string{"v1.0.0", "v1.1.0-beta.1", "v1.1.0-rc.1", "v1.2.0-alpha.1", "edge-20230101"} {
+		registry.pushFiles(tag, map[string][]byte{"cli": []byte(tag)}, nil)
+	}
+
+	dir, err := ioutil.TempDir("", "usrbin")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name    string
+		opts    []Option
+		want    string
+		wantErr error
+	}{
+		{
+			name: "stable",
+			want: "v1.0.0",
+		},
+		{
+			name: "beta",
+			opts: []Option{WithChannel("beta")},
+			want: "v1.1.0-rc.1",
+		},
+		{
+			name: "nightly",
+			opts: []Option{WithChannel("nightly")},
+			want: "v1.2.0-alpha.1",
+		},
+		{
+			name: "tag pattern",
+			opts: []Option{WithChannel("beta"), WithChannelTagPattern("beta", regexp.MustCompile(`-beta\.[0-9]+$`))},
+			want: "v1.1.0-beta.1",
+		},
+		{
+			name: "stable tag pattern",
+			opts: []Option{WithChannelTagPattern("stable", regexp.MustCompile(`^v1\.0\.`))},
+			want: "v1.0.0",
+		},
+		{
+			name:    "no matching tag",
+			opts:    []Option{WithChannelTagPattern("stable", regexp.MustCompile(`^v9\.`))},
+			wantErr: ErrReleaseNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := append([]Option{WithDockerConfig(filepath.Join(dir, "config.json")), WithPlainHTTP()}, tt.opts...)
+			c := NewOCIUpdateChecker(fmt.Sprintf("%s/usrbinapp/cli", registry.host()), opts...)
+			got, err := c.GetLatestVersion(time.Second)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got.Version)
+		})
+	}
+}
+
+func Test_ListVersions(t *testing.T) {
+	registry := newTestRegistry(t, false)
+	for _, tag := range []string{"v1.10.0", "latest", "v1.2.0", "v1.3.0-beta.1", "v1.9.0"} {
+		registry.pushFiles(tag, map[string][]byte{"cli": []byte(tag)}, nil)
+	}
+
+	dir, err := ioutil.TempDir("", "usrbin")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c := NewOCIUpdateChecker(fmt.Sprintf("%s/usrbinapp/cli", registry.host()), WithDockerConfig(filepath.Join(dir, "config.json")), WithPlainHTTP())
+	versions, err := c.(updatechecker.VersionLister).ListVersions(time.Second)
+	require.NoError(t, err)
+
+	got := []string{}
+	for _, v := range versions {
+		got = append(got, v.Version)
+	}
+	assert.Equal(t, []string{"v1.2.0", "v1.9.0", "v1.10.0"}, got)
+}
+
+func Test_DownloadVersion(t *testing.T) {
+	req := require.New(t)
+
+	registry := newTestRegistry(t, false)
+	registry.pushFiles("v1.0.0", map[string][]byte{"cli": []byte("new version")}, nil)
+
+	dir, err := ioutil.TempDir("", "usrbin")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+
+	c := NewOCIUpdateChecker(fmt.Sprintf("%s/usrbinapp/cli", registry.host()), WithPlainHTTP(), WithDockerConfig(filepath.Join(dir, "config.json")))
+	got, err := c.DownloadVersion("v1.0.0", true)
+	req.NoError(err)
+	defer os.Remove(got)
+
+	contents, err := ioutil.ReadFile(got)
+	req.NoError(err)
+	assert.Equal(t, "new version", string(contents))
+}
+
+func Test_DownloadVersionPlatform(t *testing.T) {
+	platform := fmt.Sprintf("%s/%s", runtime.GOOS, runtime.GOARCH)
+
+	tests := []struct {
+		name    string
+		push    func(registry *testRegistry)
+		wantErr error
+	}{
+		{
+			name: "image index",
+			push: func(registry *testRegistry) {
+				registry.pushIndex("v1.0.0", map[string][]byte{
+					"other/other": []byte("other"),
+					platform:      []byte("new version"),
+				})
+			},
+		},
+		{
+			name: "image index, no matching platform",
+			push: func(registry *testRegistry) {
+				registry.pushIndex("v1.0.0", map[string][]byte{
+					"other/other": []byte("other"),
+				})
+			},
+			wantErr: ErrNoMatchingArchitectures,
+		},
+		{
+			name: "layer titles",
+			push: func(registry *testRegistry) {
+				registry.pushFiles("v1.0.0", map[string][]byte{
+					"cli_other_other": []byte("other"),
+					fmt.Sprintf("cli_%s_%s", runtime.GOOS, runtime.GOARCH): []byte("new version"),
+					"cli_zzz_other": []byte("other"),
+				}, nil)
+			},
+		},
+		{
+			name: "layer titles, no matching platform",
+			push: func(registry *testRegistry) {
+				registry.pushFiles("v1.0.0", map[string][]byte{
+					"cli_other_other": []byte("other"),
+					"cli_zzz_other":   []byte("other"),
+				}, nil)
+			},
+			wantErr: ErrNoMatchingArchitectures,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := require.New(t)
+
+			registry := newTestRegistry(t, false)
+			tt.push(registry)
+
+			dir, err := ioutil.TempDir("", "usrbin")
+			req.NoError(err)
+			defer os.RemoveAll(dir)
+
+			c := NewOCIUpdateChecker(fmt.Sprintf("%s/usrbinapp/cli", registry.host()), WithPlainHTTP(), WithDockerConfig(filepath.Join(dir, "config.json")))
+			got, err := c.DownloadVersion("v1.0.0", true)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			req.NoError(err)
+			defer os.Remove(got)
+
+			contents, err := ioutil.ReadFile(got)
+			req.NoError(err)
+			assert.Equal(t, "new version", string(contents))
+
+			// only the layer for this platform is pulled
+			assert.Equal(t, []string{digest.FromString("new version").String()}, registry.blobRequests())
+		})
+	}
+}
+
+func Test_bestLayer(t *testing.T) {
+	layer := func(title string, platform string) ocispec.Descriptor {
+		annotations := map[string]string{ocispec.AnnotationTitle: title}
+		if platform != "" {
+			annotations[AnnotationPlatform] = platform
+		}
+		return ocispec.Descriptor{Digest: digest.FromString(title), Annotations: annotations}
+	}
+
+	tests := []struct {
+		name      string
+		layers    []ocispec.Descriptor
+		wantTitle string
+		wantErr   error
+	}{
+		{
+			name:    "no layers",
+			wantErr: ErrNoAssets,
+		},
+		{
+			name:      "single layer",
+			layers:    []ocispec.Descriptor{layer("cli", "")},
+			wantTitle: "cli",
+		},
+		{
+			name:      "single layer for this platform",
+			layers:    []ocispec.Descriptor{layer("cli", "linux/amd64")},
+			wantTitle: "cli",
+		},
+		{
+			name:    "single layer for another platform",
+			layers:  []ocispec.Descriptor{layer("cli", "linux/arm64")},
+			wantErr: ErrNoMatchingArchitectures,
+		},
+		{
+			name:      "single layer titled for this platform",
+			layers:    []ocispec.Descriptor{layer("cli_linux_amd64", "")},
+			wantTitle: "cli_linux_amd64",
+		},
+		{
+			name:    "single layer titled for another platform",
+			layers:  []ocispec.Descriptor{layer("cli_darwin_arm64", "")},
+			wantErr: ErrNoMatchingArchitectures,
+		},
+		{
+			name: "platform annotation",
+			layers: []ocispec.Descriptor{
+				layer("cli-a", "darwin/arm64"),
+				layer("cli-b", "linux/amd64"),
+			},
+			wantTitle: "cli-b",
+		},
+		{
+			name: "title",
+			layers: []ocispec.Descriptor{
+				layer("cli_darwin_arm64", ""),
+				layer("cli_linux_arm64", ""),
+				layer("cli_linux_amd64", ""),
+			},
+			wantTitle: "cli_linux_amd64",
+		},
+		{
+			name: "title, all arch",
+			layers: []ocispec.Descriptor{
+				layer("cli_darwin_all", ""),
+				layer("cli_linux_all", ""),
+			},
+			wantTitle: "cli_linux_all",
+		},
+		{
+			name: "no match",
+			layers: []ocispec.Descriptor{
+				layer("cli_darwin_arm64", ""),
+				layer("cli_windows_amd64", ""),
+			},
+			wantErr: ErrNoMatchingArchitectures,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := bestLayer(tt.layers, "linux", "amd64")
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantTitle, got.Annotations[ocispec.AnnotationTitle])
+		})
+	}
+}
+
+func Test_GetLatestVersionReleasedAt(t *testing.T) {
+	created := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		push func(registry *testRegistry)
+		want *time.Time
+	}{
+		{
+			name: "created annotation",
+			push: func(registry *testRegistry) {
+				registry.pushFiles("v1.0.0", map[string][]byte{"cli": []byte("cli")}, map[string]string{
+					ocispec.AnnotationCreated: created.Format(time.RFC3339),
+				})
+			},
+			want: &created,
+		},
+		{
+			name: "image config",
+			push: func(registry *testRegistry) {
+				manifest := ocispec.Manifest{
+					MediaType: ocispec.MediaTypeImageManifest,
+					Config:    registry.pushBlob(ocispec.MediaTypeImageConfig, []byte(fmt.Sprintf(`{"created": "%s"}`, created.Format(time.RFC3339)))),
+					Layers:    []ocispec.Descriptor{registry.pushBlob("application/vnd.oci.image.layer.v1.tar", []byte("cli"))},
+				}
+				manifest.SchemaVersion = 2
+				registry.pushManifest("v1.0.0", ocispec.MediaTypeImageManifest, manifest)
+			},
+			want: &created,
+		},
+		{
+			name: "image index, created annotation on platform manifest",
+			push: func(registry *testRegistry) {
+				platformManifest := registry.pushFiles("", map[string][]byte{"cli": []byte("cli")}, map[string]string{
+					ocispec.AnnotationCreated: created.Format(time.RFC3339),
+				})
+				platformManifest.Platform = &ocispec.Platform{OS: runtime.GOOS, Architecture: runtime.GOARCH}
+
+				index := ocispec.Index{
+					MediaType: ocispec.MediaTypeImageIndex,
+					Manifests: []ocispec.Descriptor{platformManifest},
+				}
+				index.SchemaVersion = 2
+				registry.pushManifest("v1.0.0", ocispec.MediaTypeImageIndex, index)
+			},
+			want: &created,
+		},
+		{
+			name: "no created time",
+			push: func(registry *testRegistry) {
+				registry.pushFiles("v1.0.0", map[string][]byte{"cli": []byte("cli")}, nil)
+			},
+			want: nil,
+		},
+		{
+			name: "missing image config",
+			push: func(registry *testRegistry) {
+				config := []byte(fmt.Sprintf(`{"created": "%s"}`, created.Format(time.RFC3339)))
+				manifest := ocispec.Manifest{
+					MediaType: ocispec.MediaTypeImageManifest,
+					Config: ocispec.Descriptor{
+						MediaType: ocispec.MediaTypeImageConfig,
+						Digest:    digest.FromBytes(config),
+						Size:      int64(len(config)),
+					},
+					Layers: []ocispec.Descriptor{registry.pushBlob("application/vnd.oci.image.layer.v1.tar", []byte("cli"))},
+				}
+				manifest.SchemaVersion = 2
+				registry.pushManifest("v1.0.0", ocispec.MediaTypeImageManifest, manifest)
+			},
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := require.New(t)
+
+			registry := newTestRegistry(t, false)
+			tt.push(registry)
+
+			dir, err := ioutil.TempDir("", "usrbin")
+			req.NoError(err)
+			defer os.RemoveAll(dir)
+
+			c := NewOCIUpdateChecker(fmt.Sprintf("%s/usrbinapp/cli", registry.host()), WithPlainHTTP(), WithDockerConfig(filepath.Join(dir, "config.json")))
+			got, err := c.GetLatestVersion(time.Second)
+			req.NoError(err)
+			assert.Equal(t, "v1.0.0", got.Version)
+
+			if tt.want == nil {
+				assert.Nil(t, got.ReleasedAt)
+				return
+			}
+
+			req.NotNil(got.ReleasedAt)
+			assert.True(t, tt.want.Equal(*got.ReleasedAt))
+		})
+	}
+}
+
+func Test_DownloadVersionDigest(t *testing.T) {
+	req := require.New(t)
+
+	dir, err := ioutil.TempDir("", "usrbin")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+
+	t.Run("pinned to the digest from GetLatestVersion", func(t *testing.T) {
+		req := require.New(t)
+
+		registry := newTestRegistry(t, false)
+		checked := registry.pushFiles("v1.0.0", map[string][]byte{"cli": []byte("checked version")}, nil)
+
+		c := NewOCIUpdateChecker(fmt.Sprintf("%s/usrbinapp/cli", registry.host()), WithPlainHTTP(), WithDockerConfig(filepath.Join(dir, "config.json")))
+		latest, err := c.GetLatestVersion(time.Second)
+		req.NoError(err)
+		assert.Equal(t, checked.Digest.String(), latest.Digest)
+
+		// move the tag
+		registry.pushFiles("v1.0.0", map[string][]byte{"cli": []byte("moved version")}, nil)
+
+		for _, version := range []string{"v1.0.0", fmt.Sprintf("v1.0.0@%s", latest.Digest)} {
+			got, err := c.DownloadVersion(version, true)
+			req.NoError(err)
+			defer os.Remove(got)
+
+			contents, err := ioutil.ReadFile(got)
+			req.NoError(err)
+			assert.Equal(t, "checked version", string(contents))
+		}
+
+		// a new checker hasn't pinned anything
+		c = NewOCIUpdateChecker(fmt.Sprintf("%s/usrbinapp/cli", registry.host()), WithPlainHTTP(), WithDockerConfig(filepath.Join(dir, "config.json")))
+		got, err := c.DownloadVersion("v1.0.0", true)
+		req.NoError(err)
+		defer os.Remove(got)
+
+		contents, err := ioutil.ReadFile(got)
+		req.NoError(err)
+		assert.Equal(t, "moved version", string(contents))
+	})
+
+	t.Run("layer doesn't match its digest", func(t *testing.T) {
+		req := require.New(t)
+
+		registry := newTestRegistry(t, false)
+		registry.pushFiles("v1.0.0", map[string][]byte{"cli": []byte("new version")}, nil)
+		registry.blobs[digest.FromString("new version")] = []byte("bad version")
+
+		c := NewOCIUpdateChecker(fmt.Sprintf("%s/usrbinapp/cli", registry.host()), WithPlainHTTP(), WithDockerConfig(filepath.Join(dir, "config.json")))
+		_, err := c.DownloadVersion("v1.0.0", true)
+		assert.ErrorIs(t, err, ErrChecksumMismatch)
+
+		got, err := c.DownloadVersion("v1.0.0", false)
+		req.NoError(err)
+		defer os.Remove(got)
+	})
+}
+
+func Test_Timeouts(t *testing.T) {
+	req := require.New(t)
+
+	dir, err := ioutil.TempDir("", "usrbin")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+
+	registry := newTestRegistry(t, false)
+	registry.pushFiles("v1.0.0", map[string][]byte{"cli": []byte("new version")}, nil)
+	registry.delay = time.Second
+
+	artifact := fmt.Sprintf("%s/usrbinapp/cli", registry.host())
+
+	t.Run("GetLatestVersion", func(t *testing.T) {
+		c := NewOCIUpdateChecker(artifact, WithPlainHTTP(), WithDockerConfig(filepath.Join(dir, "config.json")))
+		_, err := c.GetLatestVersion(50 * time.Millisecond)
+		assert.ErrorIs(t, err, ErrTimeoutExceeded)
+	})
+
+	t.Run("DownloadVersion", func(t *testing.T) {
+		c := NewOCIUpdateChecker(artifact, WithPlainHTTP(), WithDockerConfig(filepath.Join(dir, "config.json")), WithTimeout(50*time.Millisecond))
+		_, err := c.DownloadVersion("v1.0.0", true)
+		assert.ErrorIs(t, err, ErrTimeoutExceeded)
+	})
+
+	t.Run("cancelled context", func(t *testing.T) {
+		c := NewOCIUpdateChecker(artifact, WithPlainHTTP(), WithDockerConfig(filepath.Join(dir, "config.json"))).(*OCIUpdateChecker)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := c.DownloadVersionContext(ctx, "v1.0.0", true)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func Test_WithHTTPClient(t *testing.T) {
+	req := require.New(t)
+
+	dir, err := ioutil.TempDir("", "usrbin")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+
+	registry := newTestRegistry(t, true)
+	registry.pushFiles("v1.0.0", map[string][]byte{"cli": []byte("new version")}, nil)
+
+	artifact := fmt.Sprintf("%s/usrbinapp/cli", registry.host())
+
+	// the test server's client trusts its certificate, the default doesn't
+	c := NewOCIUpdateChecker(artifact, WithDockerConfig(filepath.Join(dir, "config.json")), WithHTTPClient(registry.Client()))
+	got, err := c.GetLatestVersion(time.Second)
+	req.NoError(err)
+	assert.Equal(t, "v1.0.0", got.Version)
+
+	c = NewOCIUpdateChecker(artifact, WithDockerConfig(filepath.Join(dir, "config.json")))
+	_, err = c.GetLatestVersion(time.Second)
+	assert.Error(t, err)
+}
@@ -0,0 +1,233 @@
+package oci
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// testRegistry is a minimal, in memory, read only implementation of the
+// distribution api, like a registry:2 container, for tests
+type testRegistry struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	manifests map[string]testManifest
+	blobs     map[digest.Digest][]byte
+	tags      []string
+	requests  []string
+
+	// delay is how long to wait before responding to each request
+	delay time.Duration
+}
+
+type testManifest struct {
+	mediaType string
+	content   []byte
+}
+
+func newTestRegistry(t *testing.T, useTLS bool) *testRegistry {
+	r := &testRegistry{
+		manifests: map[string]testManifest{},
+		blobs:     map[digest.Digest][]byte{},
+	}
+
+	if useTLS {
+		r.Server = httptest.NewTLSServer(r)
+	} else {
+		r.Server = httptest.NewServer(r)
+	}
+	t.Cleanup(r.Close)
+
+	return r
+}
+
+// host will return the host:port of the registry, for use in an artifact name
+func (r *testRegistry) host() string {
+	return strings.TrimPrefix(strings.TrimPrefix(r.URL, "http://"), "https://")
+}
+
+func (r *testRegistry) pushBlob(mediaType string, content []byte) ocispec.Descriptor {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	d := digest.FromBytes(content)
+	r.blobs[d] = content
+
+	return ocispec.Descriptor{
+		MediaType: mediaType,
+		Digest:    d,
+		Size:      int64(len(content)),
+	}
+}
+
+// pushManifest will store the manifest by its digest and, when tag isn't
+// empty, by the tag
+func (r *testRegistry) pushManifest(tag string, mediaType string, manifest interface{}) ocispec.Descriptor {
+	content, err := json.Marshal(manifest)
+	if err != nil {
+		panic(err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	d := digest.FromBytes(content)
+	r.manifests[d.String()] = testManifest{mediaType: mediaType, content: content}
+	if tag != "" {
+		r.manifests[tag] = testManifest{mediaType: mediaType, content: content}
+		r.tags = append(r.tags, tag)
+	}
+
+	return ocispec.Descriptor{
+		MediaType: mediaType,
+		Digest:    d,
+		Size:      int64(len(content)),
+	}
+}
+
+// pushFiles will push an image manifest with one layer per file, named
+// using the title annotation the same way that `oras push` does
+func (r *testRegistry) pushFiles(tag string, files map[string][]byte, annotations map[string]string) ocispec.Descriptor {
+	names := []string{}
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	layers := []ocispec.Descriptor{}
+	for _, name := range names {
+		layer := r.pushBlob("application/vnd.oci.image.layer.v1.tar", files[name])
+		layer.Annotations = map[string]string{
+			ocispec.AnnotationTitle: name,
+		}
+		layers = append(layers, layer)
+	}
+
+	manifest := ocispec.Manifest{
+		MediaType:   ocispec.MediaTypeImageManifest,
+		Config:      r.pushBlob(ocispec.MediaTypeImageConfig, []byte("{}")),
+		Layers:      layers,
+		Annotations: annotations,
+	}
+	manifest.SchemaVersion = 2
+
+	return r.pushManifest(tag, ocispec.MediaTypeImageManifest, manifest)
+}
+
+// pushIndex will push an image index, with a manifest holding a single
+// file for each of the platforms ("os/arch") passed in
+func (r *testRegistry) pushIndex(tag string, files map[string][]byte) ocispec.Descriptor {
+	platforms := []string{}
+	for platform := range files {
+		platforms = append(platforms, platform)
+	}
+	sort.Strings(platforms)
+
+	manifests := []ocispec.Descriptor{}
+	for _, platform := range platforms {
+		manifest := r.pushFiles("", map[string][]byte{"cli": files[platform]}, nil)
+
+		parts := strings.Split(platform, "/")
+		manifest.Platform = &ocispec.Platform{
+			OS:           parts[0],
+			Architecture: parts[1],
+		}
+		manifests = append(manifests, manifest)
+	}
+
+	index := ocispec.Index{
+		MediaType: ocispec.MediaTypeImageIndex,
+		Manifests: manifests,
+	}
+	index.SchemaVersion = 2
+
+	return r.pushManifest(tag, ocispec.MediaTypeImageIndex, index)
+}
+
+// blobRequests will return the digests of the blobs that have been fetched
+func (r *testRegistry) blobRequests() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	blobRequests := []string{}
+	for _, request := range r.requests {
+		if i := strings.Index(request, "/blobs/"); i != -1 {
+			blobRequests = append(blobRequests, request[i+len("/blobs/"):])
+		}
+	}
+
+	return blobRequests
+}
+
+func (r *testRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	delay := r.delay
+	r.mu.Unlock()
+
+	select {
+	case <-time.After(delay):
+	case <-req.Context().Done():
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.requests = append(r.requests, fmt.Sprintf("%s %s", req.Method, req.URL.Path))
+
+	path := req.URL.Path
+	switch {
+	case path == "/v2/":
+		w.WriteHeader(http.StatusOK)
+
+	case strings.HasSuffix(path, "/tags/list"):
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"name": strings.TrimSuffix(strings.TrimPrefix(path, "/v2/"), "/tags/list"),
+			"tags": r.tags,
+		})
+
+	case strings.Contains(path, "/manifests/"):
+		ref := path[strings.Index(path, "/manifests/")+len("/manifests/"):]
+		manifest, ok := r.manifests[ref]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", manifest.mediaType)
+		w.Header().Set("Docker-Content-Digest", digest.FromBytes(manifest.content).String())
+		w.Header().Set("Content-Length", fmt.Sprintf("%d", len(manifest.content)))
+		if req.Method != http.MethodHead {
+			w.Write(manifest.content)
+		}
+
+	case strings.Contains(path, "/blobs/"):
+		d := digest.Digest(path[strings.Index(path, "/blobs/")+len("/blobs/"):])
+		blob, ok := r.blobs[d]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Docker-Content-Digest", d.String())
+		w.Header().Set("Content-Length", fmt.Sprintf("%d", len(blob)))
+		if req.Method != http.MethodHead {
+			w.Write(blob)
+		}
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
@@ -1,6 +1,56 @@
 package pkgmgr
 
+import (
+	"context"
+)
+
 type ExternalPackageManager interface {
 	IsInstalled() (bool, error)
 	UpgradeCommand() string
 }
+
+// ContextExternalPackageManager is an ExternalPackageManager that stops
+// checking when the context passed in is cancelled or its deadline passes
+type ContextExternalPackageManager interface {
+	IsInstalledContext(ctx context.Context) (bool, error)
+	UpgradeCommand() string
+}
+
+// WithContext will return m as a ContextExternalPackageManager. a package
+// manager that doesn't take a context is adapted: a call returns as soon
+// as the context is done while the package manager finishes in the background
+func WithContext(m ExternalPackageManager) ContextExternalPackageManager {
+	if contextManager, ok := m.(ContextExternalPackageManager); ok {
+		return contextManager
+	}
+
+	return contextAdapter{m}
+}
+
+type contextAdapter struct {
+	ExternalPackageManager
+}
+
+type isInstalledResult struct {
+	isInstalled bool
+	err         error
+}
+
+func (a contextAdapter) IsInstalledContext(ctx context.Context) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	done := make(chan isInstalledResult, 1)
+	go func() {
+		isInstalled, err := a.IsInstalled()
+		done <- isInstalledResult{isInstalled: isInstalled, err: err}
+	}()
+
+	select {
+	case result := <-done:
+		return result.isInstalled, result.err
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+}
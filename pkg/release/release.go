@@ -0,0 +1,343 @@
+package release
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/usrbinapp/usrbin-go/pkg/archive"
+)
+
+var (
+	ErrNoMatchingArchitectures   = errors.New("no matching architectures")
+	ErrNoAssets                  = errors.New("no assets")
+	ErrChecksumMismatch          = errors.New("checksum mismatch")
+	ErrUnsupportedChecksumFormat = errors.New("unsupported checksum format")
+	ErrTimeoutExceeded           = errors.New("timeout exceeded")
+)
+
+// Asset is a single downloadable file that's attached to a release.
+// Update checkers that publish releases as a list of files convert
+// their own asset type into this so that they all share the same
+// selection and checksum rules
+type Asset struct {
+	Name        string
+	URL         string
+	ContentType string
+
+	// Header is sent with the request that downloads the asset, for
+	// sources that need authentication or content negotiation
+	Header http.Header
+
+	// Local is set when URL is a file:// url on the local filesystem. only
+	// the local update checker sets it, so that the metadata from a remote
+	// source can't make the sdk read a local file
+	Local bool
+}
+
+// BestAsset will search through the assets, find the best (most appropriate)
+// asset for the os and arch provided and return that asset
+func BestAsset(assets []Asset, goos string, goarch string) (*Asset, error) {
+	if len(assets) == 0 {
+		return nil, ErrNoAssets
+	}
+
+	// find the most appropriate asset
+	for _, asset := range assets {
+		if asset.ContentType == "application/octet-stream" {
+			continue
+		}
+
+		lowercaseName := strings.ToLower(asset.Name)
+		if strings.Contains(lowercaseName, goos) {
+			if strings.Contains(lowercaseName, goarch) {
+				return &asset, nil
+			}
+		}
+	}
+
+	// we didn't find a specific match, look for the os with "all" for the arch
+	for _, asset := range assets {
+		lowercaseName := strings.ToLower(asset.Name)
+		if strings.Contains(lowercaseName, goos) {
+			if strings.Contains(lowercaseName, "all") {
+				return &asset, nil
+			}
+		}
+	}
+
+	return nil, ErrNoMatchingArchitectures
+}
+
+// ChecksumAsset will search through the assets and attempt to find the
+// sha256 checksum for the asset provided
+// this works by looking for the asset name with the checksum appended to it
+// and then falling back to a common checksums file
+// it will return nil if there is no checksum
+func ChecksumAsset(assets []Asset, assetName string) *Asset {
+	for _, asset := range assets {
+		if strings.HasPrefix(asset.Name, assetName) {
+			if strings.HasSuffix(asset.Name, ".sha256") {
+				return &asset
+			}
+		}
+	}
+
+	// no exact match, look for a common checksums file
+	for _, asset := range assets {
+		if strings.Contains(asset.Name, "checksums") {
+			if strings.HasSuffix(asset.Name, ".txt") {
+				return &asset
+			}
+		}
+	}
+
+	return nil
+}
+
+// DownloadAndVerify will download the asset, verify it against the checksum
+// file checksumAsset (if there is one) and return a path to the file in the
+// archive that's probably the binary
+// it's the responsibility of the caller to clean up the extracted file
+func DownloadAndVerify(ctx context.Context, httpClient *http.Client, asset Asset, checksumAsset *Asset) (string, error) {
+	desiredChecksum := ""
+	if checksumAsset != nil {
+		parsedChecksum, err := DownloadAndParseChecksum(ctx, httpClient, *checksumAsset, asset.Name)
+		if err != nil {
+			return "", errors.Wrap(err, "download and parse checksum")
+		}
+		desiredChecksum = parsedChecksum
+	}
+
+	return DownloadWithChecksum(ctx, httpClient, asset, desiredChecksum)
+}
+
+// DownloadWithChecksum will download the asset, verify that its sha256
+// matches desiredChecksum (unless desiredChecksum is empty) and return a
+// path to the file in the archive that's probably the binary
+// it's the responsibility of the caller to clean up the extracted file
+func DownloadWithChecksum(ctx context.Context, httpClient *http.Client, asset Asset, desiredChecksum string) (string, error) {
+	archivePath, fileInArchivePath, err := DownloadFile(ctx, httpClient, asset)
+	if err != nil {
+		return "", errors.Wrap(err, "download file")
+	}
+	defer os.Remove(archivePath)
+
+	if desiredChecksum != "" {
+		actualChecksum, err := ChecksumFile(archivePath)
+		if err != nil {
+			return "", errors.Wrap(err, "checksum file")
+		}
+
+		if !strings.EqualFold(actualChecksum, desiredChecksum) {
+			os.Remove(fileInArchivePath)
+			return "", ErrChecksumMismatch
+		}
+	}
+
+	return fileInArchivePath, nil
+}
+
+// DownloadAndParseChecksum will download the checksum file and
+// return the checksum for assetName
+func DownloadAndParseChecksum(ctx context.Context, httpClient *http.Client, checksumAsset Asset, assetName string) (string, error) {
+	// download the file
+	body, err := get(ctx, httpClient, checksumAsset)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	return ParseChecksum(body, assetName)
+}
+
+// ParseChecksum will read a checksum file and return the checksum for assetName
+// supported formats are sha256[whitespace]filepath per line
+func ParseChecksum(r io.Reader, assetName string) (string, error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		parts := strings.Fields(line)
+		if len(parts) == 2 {
+			if strings.HasSuffix(strings.TrimSpace(parts[1]), assetName) {
+				return strings.TrimSpace(parts[0]), nil
+			}
+		}
+	}
+
+	return "", ErrUnsupportedChecksumFormat
+}
+
+// ChecksumFile will return the hex encoded sha256 of the file at path
+func ChecksumFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// DownloadFile will return two strings:
+//   - the path to the downloaded file (the archive)
+//   - the path to the file that is probably the binary
+func DownloadFile(ctx context.Context, httpClient *http.Client, asset Asset) (string, string, error) {
+	tmpFile, err := ioutil.TempFile("", "usrbin")
+	if err != nil {
+		return "", "", errors.Wrap(err, "create temp file")
+	}
+	defer tmpFile.Close()
+
+	body, err := get(ctx, httpClient, asset)
+	if err != nil {
+		os.Remove(tmpFile.Name())
+		return "", "", errors.Wrap(err, "get file")
+	}
+	defer body.Close()
+
+	_, err = io.Copy(tmpFile, body)
+	if err != nil {
+		os.Remove(tmpFile.Name())
+		return "", "", errors.Wrap(err, "copy file")
+	}
+
+	probableFile, err := archive.FindProbableFileInWhatMightBeAnArchive(tmpFile.Name())
+	if err != nil {
+		os.Remove(tmpFile.Name())
+		return "", "", errors.Wrap(err, "find probable file")
+	}
+
+	return tmpFile.Name(), probableFile, nil
+}
+
+// get will return the contents of asset. a local asset is read from its
+// file:// url, everything else is fetched with httpClient (http.DefaultClient
+// when it's nil), and must be an http or https url
+func get(ctx context.Context, httpClient *http.Client, asset Asset) (io.ReadCloser, error) {
+	parsed, err := url.Parse(asset.URL)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse url")
+	}
+
+	if asset.Local {
+		if parsed.Scheme != "file" {
+			return nil, errors.Errorf("unsupported url scheme for a local asset: %q", parsed.Scheme)
+		}
+		return os.Open(localPath(parsed))
+	}
+
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return nil, errors.Errorf("unsupported url scheme: %q", parsed.Scheme)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", asset.URL, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "new request")
+	}
+
+	for name, values := range asset.Header {
+		req.Header[name] = values
+	}
+
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, TimeoutError(err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, errors.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	return resp.Body, nil
+}
+
+// TimeoutError will return ErrTimeoutExceeded when err was caused by
+// a deadline, and err otherwise
+func TimeoutError(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrTimeoutExceeded
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ErrTimeoutExceeded
+	}
+
+	return err
+}
+
+// NextPageURL will return the url of the next page from the Link header
+// that GitHub, GitLab and Gitea send with a paginated response, or "" when
+// it's the last page
+func NextPageURL(linkHeader string) string {
+	for _, link := range strings.Split(linkHeader, ",") {
+		parts := strings.Split(link, ";")
+		for _, param := range parts[1:] {
+			if strings.TrimSpace(param) == `rel="next"` {
+				return strings.Trim(strings.TrimSpace(parts[0]), "<>")
+			}
+		}
+	}
+
+	return ""
+}
+
+// FileURL will return the file:// url for the local path
+func FileURL(path string) string {
+	slashed := filepath.ToSlash(path)
+	if !strings.HasPrefix(slashed, "/") {
+		// windows paths start with a volume name
+		slashed = "/" + slashed
+	}
+
+	u := url.URL{
+		Scheme: "file",
+		Path:   slashed,
+	}
+
+	return u.String()
+}
+
+// LocalPath will return the local path for a file:// url
+func LocalPath(fileURL string) (string, error) {
+	parsed, err := url.Parse(fileURL)
+	if err != nil {
+		return "", errors.Wrap(err, "parse url")
+	}
+
+	if parsed.Scheme != "file" {
+		return "", errors.Errorf("not a file url: %s", fileURL)
+	}
+
+	return localPath(parsed), nil
+}
+
+func localPath(u *url.URL) string {
+	p := u.Path
+	if runtime.GOOS == "windows" {
+		p = strings.TrimPrefix(p, "/")
+	}
+
+	return filepath.FromSlash(p)
+}
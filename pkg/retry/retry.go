@@ -0,0 +1,223 @@
+package retry
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Policy decides which requests are sent again, and how long to wait
+// in between
+type Policy struct {
+	// MaxAttempts is the number of times a request is sent, including the
+	// first. 1 (or less) disables retries
+	MaxAttempts int
+
+	// InitialBackoff is the wait before the first retry. it doubles for
+	// each retry after that, and a random jitter of up to half is taken off
+	InitialBackoff time.Duration
+
+	// MaxBackoff is the longest wait between attempts, including a wait that
+	// the server asks for with Retry-After or X-RateLimit-Reset. when the
+	// server asks for a longer wait, its response is returned without retrying
+	MaxBackoff time.Duration
+
+	// RetryableStatusCodes are the response status codes that are retried.
+	// a 403 that's caused by a rate limit is also retried
+	RetryableStatusCodes []int
+}
+
+// DefaultPolicy is used by the update checkers unless a policy is passed in
+var DefaultPolicy = Policy{
+	MaxAttempts:    3,
+	InitialBackoff: time.Millisecond * 500,
+	MaxBackoff:     time.Second * 10,
+	RetryableStatusCodes: []int{
+		http.StatusRequestTimeout,
+		http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout,
+	},
+}
+
+// NewClient will return a copy of httpClient that retries requests using
+// policy. a nil httpClient is treated as http.DefaultClient
+func NewClient(httpClient *http.Client, policy Policy) *http.Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	if policy.MaxAttempts <= 1 {
+		return httpClient
+	}
+
+	retryClient := *httpClient
+	retryClient.Transport = NewTransport(httpClient.Transport, policy)
+
+	return &retryClient
+}
+
+// NewTransport will return a transport that sends requests with base, and
+// retries them using policy. a nil base is treated as http.DefaultTransport
+func NewTransport(base http.RoundTripper, policy Policy) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return &transport{
+		base:   base,
+		policy: policy,
+	}
+}
+
+type transport struct {
+	base   http.RoundTripper
+	policy Policy
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+
+	for attempt := 1; ; attempt++ {
+		attemptReq := req
+		if attempt > 1 && req.Body != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			attemptReq = req.Clone(ctx)
+			attemptReq.Body = body
+		}
+
+		resp, err := t.base.RoundTrip(attemptReq)
+
+		if attempt >= t.policy.MaxAttempts || ctx.Err() != nil || !t.canRetry(req) {
+			return resp, err
+		}
+
+		if err != nil && isTLSError(err) {
+			return nil, err
+		}
+
+		wait := t.policy.backoff(attempt)
+		if err == nil {
+			if !t.policy.isRetryable(resp) {
+				return resp, nil
+			}
+
+			if serverWait, ok := requestedWait(resp, time.Now()); ok {
+				if serverWait > t.policy.MaxBackoff {
+					return resp, nil
+				}
+				wait = serverWait
+			}
+
+			// the connection can only be reused once the body has been read
+			io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 4096))
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// canRetry will return false for a request with a body that can't be
+// sent again
+func (t *transport) canRetry(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// isTLSError will return true when the server's certificate couldn't be
+// verified, or the server doesn't speak tls, which won't change by trying again
+func isTLSError(err error) bool {
+	var recordHeaderErr tls.RecordHeaderError
+	var verificationErr *tls.CertificateVerificationError
+	var unknownAuthorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+
+	return errors.As(err, &recordHeaderErr) ||
+		errors.As(err, &verificationErr) ||
+		errors.As(err, &unknownAuthorityErr) ||
+		errors.As(err, &hostnameErr) ||
+		errors.As(err, &invalidErr)
+}
+
+func (p Policy) isRetryable(resp *http.Response) bool {
+	for _, statusCode := range p.RetryableStatusCodes {
+		if resp.StatusCode == statusCode {
+			return true
+		}
+	}
+
+	return resp.StatusCode == http.StatusForbidden && isRateLimited(resp)
+}
+
+// backoff will return the exponential backoff, with jitter, before
+// the retry that follows attempt
+func (p Policy) backoff(attempt int) time.Duration {
+	backoff := p.InitialBackoff
+	for i := 1; i < attempt && backoff < p.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+		backoff = p.MaxBackoff
+	}
+
+	if backoff <= 1 {
+		return backoff
+	}
+
+	return backoff - time.Duration(rand.Int63n(int64(backoff/2)))
+}
+
+// isRateLimited will return true when the response says that the
+// client has to wait, using the headers that GitHub sends
+func isRateLimited(resp *http.Response) bool {
+	return resp.Header.Get("Retry-After") != "" || resp.Header.Get("X-RateLimit-Remaining") == "0"
+}
+
+// requestedWait will return how long the server asked the client to wait,
+// from Retry-After (seconds or a date) or, when the rate limit has been
+// used up, X-RateLimit-Reset (unix seconds)
+func requestedWait(resp *http.Response, now time.Time) (time.Duration, bool) {
+	if retryAfter := resp.Header.Get("Retry-After"); retryAfter != "" {
+		if seconds, err := strconv.Atoi(retryAfter); err == nil {
+			return nonNegative(time.Duration(seconds) * time.Second), true
+		}
+
+		if date, err := http.ParseTime(retryAfter); err == nil {
+			return nonNegative(date.Sub(now)), true
+		}
+	}
+
+	if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+			return nonNegative(time.Unix(reset, 0).Sub(now)), true
+		}
+	}
+
+	return 0, false
+}
+
+func nonNegative(d time.Duration) time.Duration {
+	if d < 0 {
+		return 0
+	}
+
+	return d
+}
@@ -0,0 +1,222 @@
+package retry
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testPolicy = Policy{
+	MaxAttempts:          3,
+	InitialBackoff:       time.Millisecond,
+	MaxBackoff:           time.Second,
+	RetryableStatusCodes: DefaultPolicy.RetryableStatusCodes,
+}
+
+func Test_Transport(t *testing.T) {
+	tests := []struct {
+		name         string
+		responses    []int
+		headers      map[string]string
+		wantStatus   int
+		wantAttempts int
+	}{
+		{
+			name:         "success",
+			responses:    []int{http.StatusOK},
+			wantStatus:   http.StatusOK,
+			wantAttempts: 1,
+		},
+		{
+			name:         "retries until success",
+			responses:    []int{http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusOK},
+			wantStatus:   http.StatusOK,
+			wantAttempts: 3,
+		},
+		{
+			name:         "gives up after max attempts",
+			responses:    []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusOK},
+			wantStatus:   http.StatusServiceUnavailable,
+			wantAttempts: 3,
+		},
+		{
+			name:         "not retryable",
+			responses:    []int{http.StatusNotFound, http.StatusOK},
+			wantStatus:   http.StatusNotFound,
+			wantAttempts: 1,
+		},
+		{
+			name:         "forbidden, not rate limited",
+			responses:    []int{http.StatusForbidden, http.StatusOK},
+			wantStatus:   http.StatusForbidden,
+			wantAttempts: 1,
+		},
+		{
+			name:         "rate limited, retry after",
+			responses:    []int{http.StatusForbidden, http.StatusOK},
+			headers:      map[string]string{"Retry-After": "0"},
+			wantStatus:   http.StatusOK,
+			wantAttempts: 2,
+		},
+		{
+			name:         "rate limited, retry after is longer than max backoff",
+			responses:    []int{http.StatusTooManyRequests, http.StatusOK},
+			headers:      map[string]string{"Retry-After": "3600"},
+			wantStatus:   http.StatusTooManyRequests,
+			wantAttempts: 1,
+		},
+		{
+			name:      "rate limited, reset is longer than max backoff",
+			responses: []int{http.StatusForbidden, http.StatusOK},
+			headers: map[string]string{
+				"X-RateLimit-Remaining": "0",
+				"X-RateLimit-Reset":     fmt.Sprintf("%d", time.Now().Add(time.Hour).Unix()),
+			},
+			wantStatus:   http.StatusForbidden,
+			wantAttempts: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := require.New(t)
+
+			var mu sync.Mutex
+			attempts := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				status := tt.responses[attempts]
+				attempts++
+				mu.Unlock()
+
+				if status != http.StatusOK {
+					for k, v := range tt.headers {
+						w.Header().Set(k, v)
+					}
+				}
+				w.WriteHeader(status)
+			}))
+			defer server.Close()
+
+			resp, err := NewClient(nil, testPolicy).Get(server.URL)
+			req.NoError(err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, tt.wantStatus, resp.StatusCode)
+			assert.Equal(t, tt.wantAttempts, attempts)
+		})
+	}
+}
+
+func Test_TransportCancelled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	policy := testPolicy
+	policy.InitialBackoff = time.Minute
+	policy.MaxBackoff = time.Minute
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+
+	r, err := http.NewRequestWithContext(ctx, "GET", server.URL, nil)
+	require.NoError(t, err)
+
+	_, err = NewClient(nil, policy).Do(r)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func Test_requestedWait(t *testing.T) {
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    time.Duration
+		wantOK  bool
+	}{
+		{
+			name:    "retry after seconds",
+			headers: map[string]string{"Retry-After": "30"},
+			want:    time.Second * 30,
+			wantOK:  true,
+		},
+		{
+			name:    "retry after date",
+			headers: map[string]string{"Retry-After": now.Add(time.Minute).Format(http.TimeFormat)},
+			want:    time.Minute,
+			wantOK:  true,
+		},
+		{
+			name: "rate limit reset",
+			headers: map[string]string{
+				"X-RateLimit-Remaining": "0",
+				"X-RateLimit-Reset":     fmt.Sprintf("%d", now.Add(time.Minute*5).Unix()),
+			},
+			want:   time.Minute * 5,
+			wantOK: true,
+		},
+		{
+			name: "rate limit not used up",
+			headers: map[string]string{
+				"X-RateLimit-Remaining": "10",
+				"X-RateLimit-Reset":     fmt.Sprintf("%d", now.Add(time.Minute*5).Unix()),
+			},
+			wantOK: false,
+		},
+		{
+			name: "reset in the past",
+			headers: map[string]string{
+				"X-RateLimit-Remaining": "0",
+				"X-RateLimit-Reset":     fmt.Sprintf("%d", now.Add(-time.Minute).Unix()),
+			},
+			want:   0,
+			wantOK: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{Header: http.Header{}}
+			for k, v := range tt.headers {
+				resp.Header.Set(k, v)
+			}
+
+			got, ok := requestedWait(resp, now)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_backoff(t *testing.T) {
+	policy := Policy{
+		InitialBackoff: time.Second,
+		MaxBackoff:     time.Second * 5,
+	}
+
+	tests := []struct {
+		attempt int
+		max     time.Duration
+	}{
+		{attempt: 1, max: time.Second},
+		{attempt: 2, max: time.Second * 2},
+		{attempt: 3, max: time.Second * 4},
+		{attempt: 4, max: time.Second * 5},
+		{attempt: 10, max: time.Second * 5},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("attempt %d", tt.attempt), func(t *testing.T) {
+			got := policy.backoff(tt.attempt)
+			assert.LessOrEqual(t, got, tt.max)
+			assert.Greater(t, got, tt.max/2)
+		})
+	}
+}
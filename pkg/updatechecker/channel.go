@@ -0,0 +1,82 @@
+package updatechecker
+
+import (
+	"strings"
+
+	"github.com/Masterminds/semver"
+)
+
+const (
+	ChannelStable  = "stable"
+	ChannelRC      = "rc"
+	ChannelBeta    = "beta"
+	ChannelAlpha   = "alpha"
+	ChannelNightly = "nightly"
+
+	// ChannelEnvVar can be set by the user to choose the channel, when
+	// the app doesn't choose one
+	ChannelEnvVar = "USRBIN_CHANNEL"
+)
+
+// channelIncludes is the prerelease identifiers that are offered on each
+// of the built in channels. every channel is also offered stable releases,
+// and a channel that isn't listed is offered its own name
+var channelIncludes = map[string][]string{
+	ChannelRC:      {ChannelRC},
+	ChannelBeta:    {ChannelBeta, ChannelRC},
+	ChannelAlpha:   {ChannelAlpha, ChannelBeta, ChannelRC},
+	ChannelNightly: {ChannelNightly, ChannelAlpha, ChannelBeta, ChannelRC},
+}
+
+// IsStableChannel will return true for the stable channel, which is
+// also the channel when none is set
+func IsStableChannel(channel string) bool {
+	return channel == "" || channel == ChannelStable
+}
+
+// InChannel will return true when version is offered on channel. stable
+// versions are offered on every channel. a prerelease is offered when its
+// first prerelease identifier, without trailing digits or separators (beta
+// in "1.2.0-beta.1" or "1.2.0-beta1"), is included in the channel.
+// prerelease is set when the source flagged the release as a prerelease,
+// which keeps it off the stable channel even when the version has no
+// prerelease
+func InChannel(version string, prerelease bool, channel string) bool {
+	parsed, err := semver.NewVersion(version)
+	if err != nil {
+		return false
+	}
+
+	if parsed.Prerelease() == "" && !prerelease {
+		return true
+	}
+
+	if IsStableChannel(channel) {
+		return false
+	}
+
+	if parsed.Prerelease() == "" {
+		// flagged as a prerelease, without saying which kind
+		return true
+	}
+
+	identifier := prereleaseIdentifier(parsed.Prerelease())
+
+	included, ok := channelIncludes[channel]
+	if !ok {
+		included = []string{channel}
+	}
+
+	for _, include := range included {
+		if strings.EqualFold(identifier, include) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func prereleaseIdentifier(prerelease string) string {
+	identifier := strings.SplitN(prerelease, ".", 2)[0]
+	return strings.TrimRight(identifier, "0123456789-_")
+}
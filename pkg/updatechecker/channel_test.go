@@ -0,0 +1,109 @@
+package updatechecker
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_InChannel(t *testing.T) {
+	tests := []struct {
+		name       string
+		version    string
+		prerelease bool
+		channel    string
+		want       bool
+	}{
+		{
+			name:    "stable on stable",
+			version: "1.2.0",
+			channel: "",
+			want:    true,
+		},
+		{
+			name:    "stable on beta",
+			version: "v1.2.0",
+			channel: "beta",
+			want:    true,
+		},
+		{
+			name:    "beta on stable",
+			version: "1.2.0-beta.1",
+			channel: "stable",
+			want:    false,
+		},
+		{
+			name:    "beta on beta",
+			version: "1.2.0-beta.1",
+			channel: "beta",
+			want:    true,
+		},
+		{
+			name:    "beta without a separator on beta",
+			version: "1.2.0-beta1",
+			channel: "beta",
+			want:    true,
+		},
+		{
+			name:    "rc on beta",
+			version: "1.2.0-rc.2",
+			channel: "beta",
+			want:    true,
+		},
+		{
+			name:    "beta on rc",
+			version: "1.2.0-beta.1",
+			channel: "rc",
+			want:    false,
+		},
+		{
+			name:    "alpha on nightly",
+			version: "1.2.0-alpha.1",
+			channel: "nightly",
+			want:    true,
+		},
+		{
+			name:    "nightly on beta",
+			version: "1.2.0-nightly.20230101",
+			channel: "beta",
+			want:    false,
+		},
+		{
+			name:    "arbitrary channel",
+			version: "1.2.0-edge.3",
+			channel: "edge",
+			want:    true,
+		},
+		{
+			name:    "beta on arbitrary channel",
+			version: "1.2.0-beta.1",
+			channel: "edge",
+			want:    false,
+		},
+		{
+			name:       "flagged as a prerelease on stable",
+			version:    "1.2.0",
+			prerelease: true,
+			channel:    "",
+			want:       false,
+		},
+		{
+			name:       "flagged as a prerelease on beta",
+			version:    "1.2.0",
+			prerelease: true,
+			channel:    "beta",
+			want:       true,
+		},
+		{
+			name:    "not semver",
+			version: "latest",
+			channel: "nightly",
+			want:    false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, InChannel(tt.version, tt.prerelease, tt.channel))
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package updatechecker
+
+import (
+	"github.com/Masterminds/semver"
+	"github.com/pkg/errors"
+)
+
+// SatisfiesConstraint will return true when version satisfies constraint.
+// every version satisfies a nil constraint, and a version that isn't semver
+// never satisfies one. a prerelease is matched as the release that it leads
+// up to ("2.0.0-beta.1" as "2.0.0"), so that a constraint doesn't hide
+// every prerelease on the beta and other channels
+func SatisfiesConstraint(version string, constraint *semver.Constraints) bool {
+	if constraint == nil {
+		return true
+	}
+
+	parsed, err := semver.NewVersion(version)
+	if err != nil {
+		return false
+	}
+
+	release, err := parsed.SetPrerelease("")
+	if err != nil {
+		return false
+	}
+
+	return constraint.Check(&release)
+}
+
+// UpdateInfoFromConstrainedVersions is UpdateInfoFromVersions, where
+// latestVersion is the latest version that satisfies constraint, and
+// newestVersion is the latest version without the constraint. newestVersion
+// is reported as OutsideConstraintVersion when it doesn't satisfy the
+// constraint, and it's newer than both latestVersion and currentVersion.
+// when latestVersion isn't newer than currentVersion, but there is a version
+// outside the constraint, the update info has an empty LatestVersion. nil is
+// only returned when neither is newer
+func UpdateInfoFromConstrainedVersions(currentVersion string, latestVersion *VersionInfo, newestVersion *VersionInfo, constraint *semver.Constraints) (*UpdateInfo, error) {
+	updateInfo, err := UpdateInfoFromVersions(currentVersion, latestVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	if newestVersion == nil || SatisfiesConstraint(newestVersion.Version, constraint) {
+		return updateInfo, nil
+	}
+
+	// the newest version is compared with the version that's installed
+	// after an upgrade, which is the current version when there's no update
+	installedVersion := currentVersion
+	if updateInfo != nil {
+		installedVersion = latestVersion.Version
+	}
+
+	newestSemver, err := semver.NewVersion(newestVersion.Version)
+	if err != nil {
+		return nil, errors.Wrap(err, "newest semver")
+	}
+
+	installedSemver, err := semver.NewVersion(installedVersion)
+	if err != nil {
+		return nil, errors.Wrap(err, "installed semver")
+	}
+
+	if !newestSemver.GreaterThan(installedSemver) {
+		return updateInfo, nil
+	}
+
+	if updateInfo == nil {
+		updateInfo = &UpdateInfo{}
+	}
+
+	updateInfo.OutsideConstraintVersion = newestVersion.Version
+	updateInfo.OutsideConstraintReleaseAt = newestVersion.ReleasedAt
+
+	return updateInfo, nil
+}
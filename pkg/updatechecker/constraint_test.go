@@ -0,0 +1,153 @@
+package updatechecker
+
+import (
+	"testing"
+
+	"github.com/Masterminds/semver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_SatisfiesConstraint(t *testing.T) {
+	tests := []struct {
+		name       string
+		version    string
+		constraint string
+		want       bool
+	}{
+		{
+			name:       "no constraint",
+			version:    "v2.0.0",
+			constraint: "",
+			want:       true,
+		},
+		{
+			name:       "satisfied",
+			version:    "v1.4.2",
+			constraint: ">=1.4, <2.0",
+			want:       true,
+		},
+		{
+			name:       "next major",
+			version:    "v2.0.0",
+			constraint: ">=1.4, <2.0",
+			want:       false,
+		},
+		{
+			name:       "prerelease of the next major",
+			version:    "v2.0.0-beta.1",
+			constraint: ">=1.4, <2.0",
+			want:       false,
+		},
+		{
+			name:       "prerelease",
+			version:    "v1.5.0-rc.1",
+			constraint: ">=1.4, <2.0",
+			want:       true,
+		},
+		{
+			name:       "not semver",
+			version:    "latest",
+			constraint: "<2.0",
+			want:       false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var constraint *semver.Constraints
+			if tt.constraint != "" {
+				parsed, err := semver.NewConstraint(tt.constraint)
+				require.NoError(t, err)
+				constraint = parsed
+			}
+
+			assert.Equal(t, tt.want, SatisfiesConstraint(tt.version, constraint))
+		})
+	}
+}
+
+func Test_UpdateInfoFromConstrainedVersions(t *testing.T) {
+	constraint, err := semver.NewConstraint(">=1.4, <2.0")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name                         string
+		currentVersion               string
+		latestVersion                *VersionInfo
+		newestVersion                *VersionInfo
+		want                         bool
+		wantLatestVersion            string
+		wantOutsideConstraintVersion string
+	}{
+		{
+			name:                         "newer major",
+			currentVersion:               "1.4.0",
+			latestVersion:                &VersionInfo{Version: "1.5.0"},
+			newestVersion:                &VersionInfo{Version: "2.1.0"},
+			want:                         true,
+			wantLatestVersion:            "1.5.0",
+			wantOutsideConstraintVersion: "2.1.0",
+		},
+		{
+			name:              "newest satisfies the constraint",
+			currentVersion:    "1.4.0",
+			latestVersion:     &VersionInfo{Version: "1.5.0"},
+			newestVersion:     &VersionInfo{Version: "1.5.0"},
+			want:              true,
+			wantLatestVersion: "1.5.0",
+		},
+		{
+			name:              "newest is older",
+			currentVersion:    "1.4.0",
+			latestVersion:     &VersionInfo{Version: "1.5.0"},
+			newestVersion:     &VersionInfo{Version: "1.3.0"},
+			want:              true,
+			wantLatestVersion: "1.5.0",
+		},
+		{
+			name:              "no newest version",
+			currentVersion:    "1.4.0",
+			latestVersion:     &VersionInfo{Version: "1.5.0"},
+			want:              true,
+			wantLatestVersion: "1.5.0",
+		},
+		{
+			name:                         "up to date, newer major",
+			currentVersion:               "1.5.0",
+			latestVersion:                &VersionInfo{Version: "1.5.0"},
+			newestVersion:                &VersionInfo{Version: "2.1.0"},
+			want:                         true,
+			wantLatestVersion:            "",
+			wantOutsideConstraintVersion: "2.1.0",
+		},
+		{
+			name:           "up to date",
+			currentVersion: "1.5.0",
+			latestVersion:  &VersionInfo{Version: "1.5.0"},
+			newestVersion:  &VersionInfo{Version: "1.5.0"},
+			want:           false,
+		},
+		{
+			name:           "up to date, newest is older",
+			currentVersion: "2.2.0",
+			latestVersion:  &VersionInfo{Version: "1.5.0"},
+			newestVersion:  &VersionInfo{Version: "2.1.0"},
+			want:           false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := UpdateInfoFromConstrainedVersions(tt.currentVersion, tt.latestVersion, tt.newestVersion, constraint)
+			require.NoError(t, err)
+
+			if !tt.want {
+				assert.Nil(t, got)
+				return
+			}
+
+			require.NotNil(t, got)
+			assert.Equal(t, tt.wantLatestVersion, got.LatestVersion)
+			assert.Equal(t, tt.wantOutsideConstraintVersion, got.OutsideConstraintVersion)
+		})
+	}
+}
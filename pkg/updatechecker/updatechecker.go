@@ -1,6 +1,10 @@
 package updatechecker
 
 import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
 	"time"
 
 	"github.com/Masterminds/semver"
@@ -10,23 +14,174 @@ import (
 type VersionInfo struct {
 	Version    string     `json:"version"`
 	ReleasedAt *time.Time `json:"releasedAt"`
+
+	// Digest is the immutable content digest that Version resolved to, for
+	// update checkers that support it. a DigestPinner's DownloadVersion
+	// accepts Version@Digest to download exactly this content
+	Digest string `json:"digest,omitempty"`
 }
 
 type UpdateInfo struct {
+	// LatestVersion is the version that an upgrade installs. it's empty when
+	// there's no upgrade, only a version outside of the version constraint
 	LatestVersion   string     `json:"latestVersion"`
 	LatestReleaseAt *time.Time `json:"latestReleaseAt"`
+	LatestDigest    string     `json:"latestDigest,omitempty"`
+
+	// OutsideConstraintVersion is a version newer than LatestVersion that
+	// doesn't satisfy the version constraint, like the next major version.
+	// it's only reported, an upgrade never installs it
+	OutsideConstraintVersion   string     `json:"outsideConstraintVersion,omitempty"`
+	OutsideConstraintReleaseAt *time.Time `json:"outsideConstraintReleaseAt,omitempty"`
 
 	CheckedAt *time.Time `json:"checkedAt"`
 
+	// CanUpgradeInPlace and ExternalUpgradeCommand are only set when
+	// there's a LatestVersion to upgrade to
 	CanUpgradeInPlace      bool   `json:"canUpgradeInPlace"`
 	ExternalUpgradeCommand string `json:"externalUpgradeCommand"`
 }
 
+// RateLimitedError is returned when the server refused a request because
+// the client has used up its rate limit. use errors.As to find it
+type RateLimitedError struct {
+	// Remaining is the number of requests that are left before Reset
+	Remaining int
+
+	// Reset is when requests will be accepted again
+	Reset time.Time
+}
+
+func (e *RateLimitedError) Error() string {
+	return fmt.Sprintf("rate limited until %s", e.Reset.Format(time.RFC3339))
+}
+
 type UpdateChecker interface {
 	GetLatestVersion(timeout time.Duration) (*VersionInfo, error)
 	DownloadVersion(version string, requireChecksumMatch bool) (string, error)
 }
 
+// ContextUpdateChecker is an UpdateChecker that stops its requests when
+// the context passed in is cancelled or its deadline passes
+type ContextUpdateChecker interface {
+	GetLatestVersionContext(ctx context.Context) (*VersionInfo, error)
+	DownloadVersionContext(ctx context.Context, version string, requireChecksumMatch bool) (string, error)
+}
+
+// VersionLister is an UpdateChecker that can list every version that it
+// offers, not just the latest. versions are filtered the same way as the
+// latest version is, by channel and version constraint, and sorted with
+// SortVersions
+type VersionLister interface {
+	ListVersions(timeout time.Duration) ([]VersionInfo, error)
+	ListVersionsContext(ctx context.Context) ([]VersionInfo, error)
+}
+
+// DigestPinner is an UpdateChecker that reports VersionInfo.Digest and
+// whose DownloadVersion accepts Version@Digest
+type DigestPinner interface {
+	PinsDigests() bool
+}
+
+// SortVersions will sort versions by semver, oldest first. versions that
+// aren't semver are sorted before all of the others
+func SortVersions(versions []VersionInfo) {
+	parsed := make(map[string]*semver.Version, len(versions))
+	for _, v := range versions {
+		if semverVersion, err := semver.NewVersion(v.Version); err == nil {
+			parsed[v.Version] = semverVersion
+		}
+	}
+
+	sort.SliceStable(versions, func(i, j int) bool {
+		a, b := parsed[versions[i].Version], parsed[versions[j].Version]
+		if a == nil || b == nil {
+			return a == nil && b != nil
+		}
+
+		return a.LessThan(b)
+	})
+}
+
+// defaultTimeout is the timeout passed to an UpdateChecker that doesn't
+// take a context, when the context has no deadline
+const defaultTimeout = time.Second * 3
+
+// WithContext will return c as a ContextUpdateChecker. an update checker
+// that doesn't take a context is adapted: the deadline of the context is
+// passed as the timeout, and a call returns as soon as the context is done
+// while the update checker finishes in the background
+func WithContext(c UpdateChecker) ContextUpdateChecker {
+	if contextChecker, ok := c.(ContextUpdateChecker); ok {
+		return contextChecker
+	}
+
+	return contextAdapter{c}
+}
+
+type contextAdapter struct {
+	UpdateChecker
+}
+
+type latestVersionResult struct {
+	versionInfo *VersionInfo
+	err         error
+}
+
+func (a contextAdapter) GetLatestVersionContext(ctx context.Context) (*VersionInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	timeout := defaultTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+
+	done := make(chan latestVersionResult, 1)
+	go func() {
+		versionInfo, err := a.GetLatestVersion(timeout)
+		done <- latestVersionResult{versionInfo: versionInfo, err: err}
+	}()
+
+	select {
+	case result := <-done:
+		return result.versionInfo, result.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+type downloadVersionResult struct {
+	path string
+	err  error
+}
+
+func (a contextAdapter) DownloadVersionContext(ctx context.Context, version string, requireChecksumMatch bool) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	done := make(chan downloadVersionResult, 1)
+	go func() {
+		path, err := a.DownloadVersion(version, requireChecksumMatch)
+		done <- downloadVersionResult{path: path, err: err}
+	}()
+
+	select {
+	case result := <-done:
+		return result.path, result.err
+	case <-ctx.Done():
+		// nobody will use the download, so don't leave it behind
+		go func() {
+			if result := <-done; result.err == nil {
+				os.Remove(result.path)
+			}
+		}()
+		return "", ctx.Err()
+	}
+}
+
 func UpdateInfoFromVersions(currentVersion string, latestVersion *VersionInfo) (*UpdateInfo, error) {
 	if latestVersion == nil {
 		return nil, errors.New("latest version is nil")
@@ -47,8 +202,10 @@ func UpdateInfoFromVersions(currentVersion string, latestVersion *VersionInfo) (
 	}
 
 	updateInfo := UpdateInfo{
-		LatestVersion:   latestVersion.Version,
-		LatestReleaseAt: latestVersion.ReleasedAt,
+		LatestVersion:     latestVersion.Version,
+		LatestReleaseAt:   latestVersion.ReleasedAt,
+		LatestDigest:      latestVersion.Digest,
+		CanUpgradeInPlace: true,
 	}
 
 	return &updateInfo, nil
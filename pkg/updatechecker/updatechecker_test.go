@@ -1,11 +1,13 @@
 package updatechecker
 
 import (
+	"context"
 	"reflect"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func Test_UpdateInfoFromVersions(t *testing.T) {
@@ -72,3 +74,102 @@ func Test_UpdateInfoFromVersions(t *testing.T) {
 		})
 	}
 }
+
+// slowUpdateChecker is an UpdateChecker that doesn't take a context
+type slowUpdateChecker struct {
+	delay      time.Duration
+	gotTimeout chan time.Duration
+}
+
+func (c slowUpdateChecker) GetLatestVersion(timeout time.Duration) (*VersionInfo, error) {
+	c.gotTimeout <- timeout
+	time.Sleep(c.delay)
+	return &VersionInfo{Version: "1.0.0"}, nil
+}
+
+func (c slowUpdateChecker) DownloadVersion(version string, requireChecksumMatch bool) (string, error) {
+	time.Sleep(c.delay)
+	return "", nil
+}
+
+func Test_WithContext(t *testing.T) {
+	tests := []struct {
+		name        string
+		delay       time.Duration
+		ctxTimeout  time.Duration
+		wantVersion string
+		wantErr     error
+	}{
+		{
+			name:        "finishes before the deadline",
+			delay:       0,
+			ctxTimeout:  time.Minute,
+			wantVersion: "1.0.0",
+		},
+		{
+			name:       "returns at the deadline",
+			delay:      time.Minute,
+			ctxTimeout: time.Millisecond * 50,
+			wantErr:    context.DeadlineExceeded,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := slowUpdateChecker{
+				delay:      tt.delay,
+				gotTimeout: make(chan time.Duration, 1),
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), tt.ctxTimeout)
+			defer cancel()
+
+			got, err := WithContext(c).GetLatestVersionContext(ctx)
+
+			gotTimeout := <-c.gotTimeout
+			assert.LessOrEqual(t, gotTimeout, tt.ctxTimeout)
+			assert.Greater(t, gotTimeout, time.Duration(0))
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantVersion, got.Version)
+		})
+	}
+}
+
+func Test_WithContextCancelled(t *testing.T) {
+	c := slowUpdateChecker{
+		delay:      time.Minute,
+		gotTimeout: make(chan time.Duration, 1),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(time.Millisecond * 50)
+		cancel()
+	}()
+
+	_, err := WithContext(c).DownloadVersionContext(ctx, "1.0.0", true)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func Test_SortVersions(t *testing.T) {
+	versions := []VersionInfo{
+		{Version: "v1.10.0"},
+		{Version: "v1.2.0"},
+		{Version: "latest"},
+		{Version: "v1.10.0-rc.1"},
+		{Version: "v1.9.3"},
+	}
+
+	SortVersions(versions)
+
+	got := []string{}
+	for _, v := range versions {
+		got = append(got, v.Version)
+	}
+	assert.Equal(t, []string{"latest", "v1.2.0", "v1.9.3", "v1.10.0-rc.1", "v1.10.0"}, got)
+}
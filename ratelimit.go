@@ -0,0 +1,100 @@
+package usrbin
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/usrbinapp/usrbin-go/pkg/updatechecker"
+)
+
+const (
+	rateLimitCacheFile = "ratelimit.json"
+)
+
+// rateLimit remembers that the update checker was rate limited, so that
+// no more requests are made until the rate limit resets. it's shared by
+// all copies of the sdk. when there's a cache dir, the rate limit is also
+// stored there, so that other runs of the app don't make requests either
+type rateLimit struct {
+	mu  sync.Mutex
+	err *updatechecker.RateLimitedError
+
+	// dir is the cache dir, or "" when there isn't a cache
+	dir string
+}
+
+// cachedRateLimit is the document in the cache dir
+type cachedRateLimit struct {
+	Remaining int       `json:"remaining"`
+	Reset     time.Time `json:"reset"`
+}
+
+// active will return the rate limited error while now is before the reset
+func (r *rateLimit) active(now time.Time) *updatechecker.RateLimitedError {
+	if r == nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// another run of the app may have been rate limited since
+	if (r.err == nil || !now.Before(r.err.Reset)) && r.dir != "" {
+		r.err = readRateLimit(r.dir)
+	}
+
+	if r.err == nil || !now.Before(r.err.Reset) {
+		return nil
+	}
+
+	return r.err
+}
+
+// set will remember err, writing it to the cache dir when there is one
+func (r *rateLimit) set(err *updatechecker.RateLimitedError) error {
+	if r == nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.err = err
+
+	if r.dir == "" {
+		return nil
+	}
+
+	b, marshalErr := json.Marshal(cachedRateLimit{
+		Remaining: err.Remaining,
+		Reset:     err.Reset,
+	})
+	if marshalErr != nil {
+		return errors.Wrap(marshalErr, "marshal rate limit")
+	}
+
+	return writeCacheFile(r.dir, rateLimitCacheFile, b)
+}
+
+// readRateLimit will return the rate limit stored in dir, or nil when
+// there isn't one
+func readRateLimit(dir string) *updatechecker.RateLimitedError {
+	b, err := ioutil.ReadFile(filepath.Join(dir, rateLimitCacheFile))
+	if err != nil {
+		return nil
+	}
+
+	cached := cachedRateLimit{}
+	if err := json.Unmarshal(b, &cached); err != nil {
+		return nil
+	}
+
+	return &updatechecker.RateLimitedError{
+		Remaining: cached.Remaining,
+		Reset:     cached.Reset,
+	}
+}
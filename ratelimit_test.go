@@ -0,0 +1,70 @@
+package usrbin
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/usrbinapp/usrbin-go/pkg/updatechecker"
+)
+
+func Test_GetUpdateInfoRateLimitedAcrossRuns(t *testing.T) {
+	req := require.New(t)
+
+	dir, err := ioutil.TempDir("", "usrbin")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+
+	reset := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", fmt.Sprintf("%d", reset.Unix()))
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	// each run of the app is a new sdk, sharing the cache dir
+	for i := 0; i < 2; i++ {
+		sdk, err := New("1.0.0", UsingGitHubEnterpriseUpdateChecker(server.URL, "usrbinapp/cli"), UsingCacheDir(dir, time.Hour))
+		req.NoError(err)
+
+		_, err = sdk.GetUpdateInfo()
+
+		var rateLimitedErr *updatechecker.RateLimitedError
+		req.ErrorAs(err, &rateLimitedErr)
+		assert.True(t, reset.Equal(rateLimitedErr.Reset))
+	}
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+}
+
+func Test_rateLimitCacheDir(t *testing.T) {
+	req := require.New(t)
+
+	dir, err := ioutil.TempDir("", "usrbin")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+
+	reset := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	r := &rateLimit{dir: dir}
+	req.NoError(r.set(&updatechecker.RateLimitedError{Reset: reset}))
+
+	other := &rateLimit{dir: dir}
+	assert.NotNil(t, other.active(reset.Add(-time.Minute)))
+	assert.Nil(t, other.active(reset))
+
+	var nilRateLimit *rateLimit
+	assert.Nil(t, nilRateLimit.active(reset))
+	assert.NoError(t, nilRateLimit.set(&updatechecker.RateLimitedError{Reset: reset}))
+}
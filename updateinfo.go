@@ -1,6 +1,7 @@
 package usrbin
 
 import (
+	"context"
 	"time"
 
 	"github.com/pkg/errors"
@@ -8,11 +9,56 @@ import (
 )
 
 // GetUpdateInfo will return the latest version
+// when there is a cache, the result of the last check is returned until
+// it's older than the cache's ttl
+// when the update checker is rate limited, a *updatechecker.RateLimitedError
+// is returned, and returned again without making a request until the reset.
+// when there is a cache, the reset is stored in it, so that other runs of the
+// app don't make a request either
 func (s SDK) GetUpdateInfo() (*updatechecker.UpdateInfo, error) {
+	return s.GetUpdateInfoContext(context.Background())
+}
+
+// GetUpdateInfoContext is GetUpdateInfo, stopping when ctx is done
+func (s SDK) GetUpdateInfoContext(ctx context.Context) (*updatechecker.UpdateInfo, error) {
 	checkedAt := time.Now()
 
-	latestVersion, err := s.updateChecker.GetLatestVersion(s.httpTimeout)
+	if updateInfo, ok := s.updateInfoCache.read(s.cacheKey(), checkedAt); ok {
+		return updateInfo, nil
+	}
+
+	if rateLimitedErr := s.rateLimit.active(checkedAt); rateLimitedErr != nil {
+		return nil, rateLimitedErr
+	}
+
+	updateInfo, err := s.checkForUpdate(ctx, checkedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.updateInfoCache.write(s.cacheKey(), checkedAt, updateInfo); err != nil {
+		s.logf("failed to cache update info: %v", err)
+	}
+
+	return updateInfo, nil
+}
+
+// checkForUpdate will ask the update checker for the latest version,
+// returning nil when it's not newer than the current version. when there's
+// a version constraint, the newest version is also checked, to report one
+// that's outside of the constraint
+func (s SDK) checkForUpdate(ctx context.Context, checkedAt time.Time) (*updatechecker.UpdateInfo, error) {
+	checkCtx, cancel := context.WithTimeout(ctx, s.httpTimeout)
+	defer cancel()
+
+	latestVersion, err := updatechecker.WithContext(s.updateChecker).GetLatestVersionContext(checkCtx)
 	if err != nil {
+		var rateLimitedErr *updatechecker.RateLimitedError
+		if errors.As(err, &rateLimitedErr) {
+			if err := s.rateLimit.set(rateLimitedErr); err != nil {
+				s.logf("failed to cache rate limit: %v", err)
+			}
+		}
 		return nil, errors.Wrap(err, "get latest version")
 	}
 
@@ -20,7 +66,9 @@ func (s SDK) GetUpdateInfo() (*updatechecker.UpdateInfo, error) {
 		return nil, nil
 	}
 
-	updateInfo, err := updatechecker.UpdateInfoFromVersions(s.version, latestVersion)
+	newestVersion := s.getNewestVersion(checkCtx)
+
+	updateInfo, err := updatechecker.UpdateInfoFromConstrainedVersions(s.version, latestVersion, newestVersion, s.parsedVersionConstraint)
 	if err != nil {
 		return nil, errors.Wrap(err, "update info from versions")
 	}
@@ -28,9 +76,30 @@ func (s SDK) GetUpdateInfo() (*updatechecker.UpdateInfo, error) {
 		return nil, nil
 	}
 
-	updateInfo.ExternalUpgradeCommand = s.ExternalUpgradeCommand()
-	updateInfo.CanUpgradeInPlace = updateInfo.ExternalUpgradeCommand == ""
+	// there's nothing to upgrade to when the only newer version is
+	// outside of the version constraint
+	if updateInfo.LatestVersion != "" {
+		updateInfo.ExternalUpgradeCommand = s.ExternalUpgradeCommandContext(ctx)
+		updateInfo.CanUpgradeInPlace = updateInfo.ExternalUpgradeCommand == ""
+	}
 	updateInfo.CheckedAt = &checkedAt
 
 	return updateInfo, nil
 }
+
+// getNewestVersion will return the newest version without the version
+// constraint, or nil when there's no constraint. it's only reported, so
+// a failed check is logged instead of failing the update check
+func (s SDK) getNewestVersion(ctx context.Context) *updatechecker.VersionInfo {
+	if s.unconstrainedUpdateChecker == nil {
+		return nil
+	}
+
+	newestVersion, err := updatechecker.WithContext(s.unconstrainedUpdateChecker).GetLatestVersionContext(ctx)
+	if err != nil {
+		s.logf("failed to get the newest version outside of the version constraint: %v", err)
+		return nil
+	}
+
+	return newestVersion
+}
@@ -0,0 +1,48 @@
+package usrbin
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_GetUpdateInfoVersionConstraint(t *testing.T) {
+	req := require.New(t)
+
+	dir, err := ioutil.TempDir("", "usrbin")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+
+	for _, version := range []string{"v1.4.0", "v1.5.0", "v1.6.0-beta.1", "v2.0.0"} {
+		req.NoError(os.Mkdir(filepath.Join(dir, version), 0755))
+	}
+
+	sdk, err := New("1.4.0", UsingLocalUpdateChecker(dir), UsingVersionConstraint(">=1.4, <2.0"))
+	req.NoError(err)
+
+	updateInfo, err := sdk.GetUpdateInfo()
+	req.NoError(err)
+	req.NotNil(updateInfo)
+	assert.Equal(t, "v1.5.0", updateInfo.LatestVersion)
+	assert.Equal(t, "v2.0.0", updateInfo.OutsideConstraintVersion)
+	assert.NotNil(t, updateInfo.OutsideConstraintReleaseAt)
+	assert.True(t, updateInfo.CanUpgradeInPlace)
+
+	sdk, err = New("1.5.0", UsingLocalUpdateChecker(dir), UsingVersionConstraint(">=1.4, <2.0"))
+	req.NoError(err)
+
+	updateInfo, err = sdk.GetUpdateInfo()
+	req.NoError(err)
+	req.NotNil(updateInfo)
+	assert.Equal(t, "", updateInfo.LatestVersion)
+	assert.Equal(t, "v2.0.0", updateInfo.OutsideConstraintVersion)
+	assert.False(t, updateInfo.CanUpgradeInPlace)
+	assert.Equal(t, "", updateInfo.ExternalUpgradeCommand)
+
+	_, err = New("1.4.0", UsingVersionConstraint("not a constraint"))
+	assert.Error(t, err)
+}
@@ -1,17 +1,46 @@
 package usrbin
 
 import (
+	"context"
+	"fmt"
 	"os"
 
-	"github.com/minio/selfupdate"
+	"github.com/Masterminds/semver"
 	"github.com/pkg/errors"
 	"github.com/usrbinapp/usrbin-go/pkg/logger"
+	"github.com/usrbinapp/usrbin-go/pkg/pkgmgr"
+	"github.com/usrbinapp/usrbin-go/pkg/updatechecker"
 )
 
+var (
+	ErrDowngradeNotAllowed      = errors.New("version is older than the current version, and downgrades aren't allowed")
+	ErrOutsideVersionConstraint = errors.New("version doesn't satisfy the version constraint")
+)
+
+// UpgradeOption is a functional option for UpgradeTo
+type UpgradeOption func(*upgradeOptions)
+
+type upgradeOptions struct {
+	allowDowngrade bool
+}
+
+// AllowDowngrade will let UpgradeTo install a version that's older than
+// the current version
+func AllowDowngrade() UpgradeOption {
+	return func(o *upgradeOptions) {
+		o.allowDowngrade = true
+	}
+}
+
 // CanSupportUpgrade
 func (s SDK) CanSupportUpgrade() (bool, error) {
+	return s.CanSupportUpgradeContext(context.Background())
+}
+
+// CanSupportUpgradeContext is CanSupportUpgrade, stopping when ctx is done
+func (s SDK) CanSupportUpgradeContext(ctx context.Context) (bool, error) {
 	for _, epm := range s.externalPackageManagers {
-		isInstalled, err := epm.IsInstalled()
+		isInstalled, err := pkgmgr.WithContext(epm).IsInstalledContext(ctx)
 		if err != nil {
 			return false, err
 		}
@@ -24,8 +53,13 @@ func (s SDK) CanSupportUpgrade() (bool, error) {
 }
 
 func (s SDK) ExternalUpgradeCommand() string {
+	return s.ExternalUpgradeCommandContext(context.Background())
+}
+
+// ExternalUpgradeCommandContext is ExternalUpgradeCommand, stopping when ctx is done
+func (s SDK) ExternalUpgradeCommandContext(ctx context.Context) string {
 	for _, epm := range s.externalPackageManagers {
-		isInstalled, err := epm.IsInstalled()
+		isInstalled, err := pkgmgr.WithContext(epm).IsInstalledContext(ctx)
 		if err != nil {
 			return ""
 		}
@@ -40,21 +74,124 @@ func (s SDK) ExternalUpgradeCommand() string {
 // Upgrade is the entrypoint that the app will use to perform an in-place upgrade
 // we need to assume that the app is running and we are running in the main thread
 func (s SDK) Upgrade() error {
+	return s.UpgradeContext(context.Background())
+}
+
+// UpgradeContext is Upgrade, stopping when ctx is done. once the new
+// version has been downloaded the executable is replaced, even if ctx
+// is done while that happens
+func (s SDK) UpgradeContext(ctx context.Context) error {
 	// assume the latest
-	updateInfo, err := s.GetUpdateInfo()
+	updateInfo, err := s.GetUpdateInfoContext(ctx)
 	if err != nil {
 		return errors.Wrap(err, "get update info")
 	}
 
-	if updateInfo == nil {
+	// an update info without a latest version only reports a version
+	// outside of the version constraint, which is never upgraded to
+	if updateInfo == nil || updateInfo.LatestVersion == "" {
 		return errors.New("no update info")
 	}
 
-	newVersionPath, err := s.updateChecker.DownloadVersion(updateInfo.LatestVersion, true)
+	return s.install(ctx, s.pinnedVersion(updateInfo))
+}
+
+// pinnedVersion will return the latest version in updateInfo, pinned to
+// the digest that was checked when the update checker supports it, so that
+// a tag that moves in between can't change what's installed
+func (s SDK) pinnedVersion(updateInfo *updatechecker.UpdateInfo) string {
+	pinner, ok := s.updateChecker.(updatechecker.DigestPinner)
+	if !ok || !pinner.PinsDigests() || updateInfo.LatestDigest == "" {
+		return updateInfo.LatestVersion
+	}
+
+	return fmt.Sprintf("%s@%s", updateInfo.LatestVersion, updateInfo.LatestDigest)
+}
+
+// UpgradeTo will replace the executable with version, which can be newer or,
+// when AllowDowngrade is passed, older than the current version. version has
+// to satisfy the version constraint. nothing is done when version is the
+// current version
+func (s SDK) UpgradeTo(version string, opts ...UpgradeOption) error {
+	return s.UpgradeToContext(context.Background(), version, opts...)
+}
+
+// UpgradeToContext is UpgradeTo, stopping when ctx is done. once the
+// version has been downloaded the executable is replaced, even if ctx
+// is done while that happens
+func (s SDK) UpgradeToContext(ctx context.Context, version string, opts ...UpgradeOption) error {
+	options := upgradeOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	requestedSemver, err := semver.NewVersion(version)
+	if err != nil {
+		return errors.Wrap(err, "parse version")
+	}
+
+	currentSemver, err := semver.NewVersion(s.version)
+	if err != nil {
+		return errors.Wrap(err, "parse current version")
+	}
+
+	if requestedSemver.Equal(currentSemver) {
+		return nil
+	}
+
+	if requestedSemver.LessThan(currentSemver) && !options.allowDowngrade {
+		return ErrDowngradeNotAllowed
+	}
+
+	if !updatechecker.SatisfiesConstraint(version, s.parsedVersionConstraint) {
+		return ErrOutsideVersionConstraint
+	}
+
+	return s.install(ctx, s.resolveVersion(ctx, requestedSemver, version))
+}
+
+// resolveVersion will return the name that the update checker uses for
+// version, so that "1.8.2" can be passed for a release tagged "v1.8.2".
+// version is returned as it is when the update checker can't list versions,
+// or none of them match
+func (s SDK) resolveVersion(ctx context.Context, requestedSemver *semver.Version, version string) string {
+	versions, err := s.ListVersionsContext(ctx)
+	if err != nil {
+		return version
+	}
+
+	for _, v := range versions {
+		parsed, err := semver.NewVersion(v.Version)
+		if err == nil && parsed.Equal(requestedSemver) {
+			return v.Version
+		}
+	}
+
+	return version
+}
+
+// install will download version and replace the executable with it
+func (s SDK) install(ctx context.Context, version string) error {
+	downloadCtx, cancel := context.WithTimeout(ctx, s.httpTimeout)
+	defer cancel()
+
+	newVersionPath, err := updatechecker.WithContext(s.updateChecker).DownloadVersionContext(downloadCtx, version, true)
 	if err != nil {
 		return errors.Wrap(err, "download version")
 	}
 
+	if err := ctx.Err(); err != nil {
+		os.Remove(newVersionPath)
+		return err
+	}
+
+	if s.healthCheck != nil {
+		if err := s.healthCheck.run(ctx, newVersionPath, version); err != nil {
+			os.Remove(newVersionPath)
+			return errors.Wrap(err, "health check")
+		}
+	}
+
 	f, err := os.Open(newVersionPath)
 	if err != nil {
 		return errors.Wrap(err, "open new version")
@@ -65,11 +202,14 @@ func (s SDK) Upgrade() error {
 		}
 	}()
 
-	err = selfupdate.Apply(f, selfupdate.Options{})
+	exe, err := executablePath()
 	if err != nil {
+		return errors.Wrap(err, "executable path")
+	}
+
+	if err := s.replaceExecutable(exe, f); err != nil {
 		return errors.Wrap(err, "apply update")
 	}
 
 	return nil
-
 }
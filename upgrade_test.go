@@ -0,0 +1,121 @@
+package usrbin
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Masterminds/semver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/usrbinapp/usrbin-go/pkg/updatechecker"
+)
+
+func Test_UpgradeTo(t *testing.T) {
+	tests := []struct {
+		name    string
+		version string
+		opts    []UpgradeOption
+		wantErr error
+	}{
+		{
+			name:    "current version",
+			version: "v1.5.0",
+		},
+		{
+			name:    "downgrade",
+			version: "1.4.0",
+			wantErr: ErrDowngradeNotAllowed,
+		},
+		{
+			name:    "outside the constraint",
+			version: "2.0.0",
+			wantErr: ErrOutsideVersionConstraint,
+		},
+		{
+			name:    "downgrade outside the constraint",
+			version: "1.0.0",
+			opts:    []UpgradeOption{AllowDowngrade()},
+			wantErr: ErrOutsideVersionConstraint,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sdk, err := New("1.5.0", UsingVersionConstraint(">=1.2, <2.0"))
+			require.NoError(t, err)
+
+			err = sdk.UpgradeTo(tt.version, tt.opts...)
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+				return
+			}
+
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func Test_pinnedVersion(t *testing.T) {
+	updateInfo := &updatechecker.UpdateInfo{
+		LatestVersion: "v1.2.0",
+		LatestDigest:  "sha256:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+	}
+
+	tests := []struct {
+		name string
+		opt  Option
+		want string
+	}{
+		{
+			name: "oci",
+			opt:  UsingOCIUpdateChecker("ghcr.io/usrbinapp/cli"),
+			want: "v1.2.0@sha256:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+		},
+		{
+			name: "manifest",
+			opt:  UsingManifestUpdateChecker("https://example.com/cli/manifest.json"),
+			want: "v1.2.0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sdk, err := New("1.0.0", tt.opt)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, sdk.pinnedVersion(updateInfo))
+		})
+	}
+}
+
+func Test_resolveVersion(t *testing.T) {
+	req := require.New(t)
+
+	dir, err := ioutil.TempDir("", "usrbin")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+
+	for _, version := range []string{"v1.8.1", "v1.8.2"} {
+		req.NoError(os.Mkdir(filepath.Join(dir, version), 0755))
+	}
+
+	sdk, err := New("1.9.0", UsingLocalUpdateChecker(dir))
+	req.NoError(err)
+
+	tests := []struct {
+		version string
+		want    string
+	}{
+		{version: "1.8.2", want: "v1.8.2"},
+		{version: "v1.8.1", want: "v1.8.1"},
+		{version: "1.7.0", want: "1.7.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			requestedSemver, err := semver.NewVersion(tt.version)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.want, sdk.resolveVersion(context.Background(), requestedSemver, tt.version))
+		})
+	}
+}
@@ -1,11 +1,23 @@
 package usrbin
 
 import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/Masterminds/semver"
+	"github.com/pkg/errors"
+	"github.com/usrbinapp/usrbin-go/pkg/gitea"
 	"github.com/usrbinapp/usrbin-go/pkg/github"
+	"github.com/usrbinapp/usrbin-go/pkg/gitlab"
 	"github.com/usrbinapp/usrbin-go/pkg/homebrew"
+	"github.com/usrbinapp/usrbin-go/pkg/local"
+	"github.com/usrbinapp/usrbin-go/pkg/manifest"
 	"github.com/usrbinapp/usrbin-go/pkg/oci"
+	"github.com/usrbinapp/usrbin-go/pkg/retry"
+	"github.com/usrbinapp/usrbin-go/pkg/updatechecker"
 )
 
 // Option is a functional option for configuring the client
@@ -21,19 +33,150 @@ func UsingLogger(logger Logger) Option {
 }
 
 // Using GitHubUpdateChecker will cause the repo passed in
+// to be the source of truth when checking for new updates.
+// pass github.WithToken to read from a private repo
+func UsingGitHubUpdateChecker(repo string, opts ...github.Option) Option {
+	return func(sdk *SDK) error {
+		sdk.newUpdateChecker = func(sdk *SDK) updatechecker.UpdateChecker {
+			return github.NewGitHubUpdateChecker(repo, sdk.githubOptions(opts)...)
+		}
+		return nil
+	}
+}
+
+// githubOptions will return the options for a GitHub update checker that
+// come from the sdk, followed by opts so that they take precedence
+func (sdk *SDK) githubOptions(opts []github.Option) []github.Option {
+	sdkOpts := []github.Option{
+		github.WithHTTPClient(sdk.httpClient),
+		github.WithRetryPolicy(sdk.retryPolicy),
+		github.WithChannel(sdk.channel),
+		github.WithVersionConstraint(sdk.parsedVersionConstraint),
+	}
+
+	if sdk.updateInfoCache != nil {
+		sdkOpts = append(sdkOpts, github.WithCacheDir(filepath.Join(sdk.updateInfoCache.dir, "github")))
+	}
+
+	return append(sdkOpts, opts...)
+}
+
+// UsingGitHubEnterpriseUpdateChecker will cause the owner/repo passed in,
+// hosted on the GitHub Enterprise Server at baseURL (https://github.example.com),
+// to be the source of truth when checking for new updates. unless
+// github.WithToken is passed, the token is read from GH_ENTERPRISE_TOKEN or
+// GITHUB_ENTERPRISE_TOKEN, falling back to GH_TOKEN or GITHUB_TOKEN
+func UsingGitHubEnterpriseUpdateChecker(baseURL string, repo string, opts ...github.Option) Option {
+	return func(sdk *SDK) error {
+		sdk.newUpdateChecker = func(sdk *SDK) updatechecker.UpdateChecker {
+			return github.NewGitHubEnterpriseUpdateChecker(baseURL, repo, sdk.githubOptions(opts)...)
+		}
+		return nil
+	}
+}
+
+// UsingGitLabUpdateChecker will cause the gitlab.com project passed in
 // to be the source of truth when checking for new updates
-func UsingGitHubUpdateChecker(repo string) Option {
+func UsingGitLabUpdateChecker(project string) Option {
 	return func(sdk *SDK) error {
-		sdk.updateChecker = github.NewGitHubUpdateChecker(repo)
+		sdk.newUpdateChecker = func(sdk *SDK) updatechecker.UpdateChecker {
+			return gitlab.NewGitLabUpdateChecker(
+				gitlab.DefaultHost, project,
+				gitlab.WithHTTPClient(sdk.httpClient),
+				gitlab.WithRetryPolicy(sdk.retryPolicy),
+				gitlab.WithChannel(sdk.channel),
+				gitlab.WithVersionConstraint(sdk.parsedVersionConstraint),
+			)
+		}
 		return nil
 	}
 }
 
-// UsingOCIUpdateChecker will cause the image passed in
+// UsingSelfManagedGitLabUpdateChecker will cause the project passed in,
+// hosted on the GitLab instance at baseURL (https://gitlab.example.com),
+// to be the source of truth when checking for new updates
+func UsingSelfManagedGitLabUpdateChecker(baseURL string, project string) Option {
+	return func(sdk *SDK) error {
+		sdk.newUpdateChecker = func(sdk *SDK) updatechecker.UpdateChecker {
+			return gitlab.NewGitLabUpdateChecker(
+				baseURL, project,
+				gitlab.WithHTTPClient(sdk.httpClient),
+				gitlab.WithRetryPolicy(sdk.retryPolicy),
+				gitlab.WithChannel(sdk.channel),
+				gitlab.WithVersionConstraint(sdk.parsedVersionConstraint),
+			)
+		}
+		return nil
+	}
+}
+
+// UsingGiteaUpdateChecker will cause the owner/repo passed in, hosted
+// on the Gitea or Forgejo instance at baseURL (https://codeberg.org),
 // to be the source of truth when checking for new updates
-func UsingOCIUpdateChecker(artifact string) Option {
+func UsingGiteaUpdateChecker(baseURL string, repo string) Option {
 	return func(sdk *SDK) error {
-		sdk.updateChecker = oci.NewOCIUpdateChecker(artifact)
+		sdk.newUpdateChecker = func(sdk *SDK) updatechecker.UpdateChecker {
+			return gitea.NewGiteaUpdateChecker(
+				baseURL, repo,
+				gitea.WithHTTPClient(sdk.httpClient),
+				gitea.WithRetryPolicy(sdk.retryPolicy),
+				gitea.WithChannel(sdk.channel),
+				gitea.WithVersionConstraint(sdk.parsedVersionConstraint),
+			)
+		}
+		return nil
+	}
+}
+
+// UsingManifestUpdateChecker will cause the JSON manifest at the url
+// passed in to be the source of truth when checking for new updates.
+// See the manifest package for the format of the file
+func UsingManifestUpdateChecker(manifestURL string) Option {
+	return func(sdk *SDK) error {
+		sdk.newUpdateChecker = func(sdk *SDK) updatechecker.UpdateChecker {
+			return manifest.NewManifestUpdateChecker(
+				manifestURL,
+				manifest.WithHTTPClient(sdk.httpClient),
+				manifest.WithRetryPolicy(sdk.retryPolicy),
+				manifest.WithChannel(sdk.channel),
+				manifest.WithVersionConstraint(sdk.parsedVersionConstraint),
+			)
+		}
+		return nil
+	}
+}
+
+// UsingLocalUpdateChecker will cause the directory (or file:// url) passed
+// in, which has one subdirectory per version, to be the source of truth when
+// checking for new updates
+func UsingLocalUpdateChecker(dir string) Option {
+	return func(sdk *SDK) error {
+		sdk.newUpdateChecker = func(sdk *SDK) updatechecker.UpdateChecker {
+			return local.NewLocalUpdateChecker(
+				dir,
+				local.WithChannel(sdk.channel),
+				local.WithVersionConstraint(sdk.parsedVersionConstraint),
+			)
+		}
+		return nil
+	}
+}
+
+// UsingOCIUpdateChecker will cause the image passed in
+// to be the source of truth when checking for new updates.
+// credentials are read from the docker config unless
+// oci.WithBasicAuth or oci.WithToken are passed
+func UsingOCIUpdateChecker(artifact string, opts ...oci.Option) Option {
+	return func(sdk *SDK) error {
+		sdk.newUpdateChecker = func(sdk *SDK) updatechecker.UpdateChecker {
+			opts := append([]oci.Option{
+				oci.WithHTTPClient(sdk.httpClient),
+				oci.WithRetryPolicy(sdk.retryPolicy),
+				oci.WithChannel(sdk.channel),
+				oci.WithVersionConstraint(sdk.parsedVersionConstraint),
+			}, opts...)
+			return oci.NewOCIUpdateChecker(artifact, opts...)
+		}
 		return nil
 	}
 }
@@ -57,17 +200,161 @@ func UsingHttpTimeout(d time.Duration) Option {
 	}
 }
 
+// UsingHTTPClient will cause all http requests made by the update checker,
+// including downloads, to use the client passed in. use this to configure
+// proxies, root cas, client certificates or to add headers to each request
+func UsingHTTPClient(httpClient *http.Client) Option {
+	return func(sdk *SDK) error {
+		sdk.httpClient = httpClient
+		return nil
+	}
+}
+
+// UsingTransport will cause all http requests made by the update checker,
+// including downloads, to be sent with the transport passed in
+func UsingTransport(transport http.RoundTripper) Option {
+	return func(sdk *SDK) error {
+		sdk.httpClient = &http.Client{
+			Transport: transport,
+		}
+		return nil
+	}
+}
+
+// UsingRetryPolicy will set how requests that fail with a network error or
+// a retryable status code are retried. retry.DefaultPolicy is used by default,
+// and a policy with MaxAttempts set to 1 disables retries
+func UsingRetryPolicy(policy retry.Policy) Option {
+	return func(sdk *SDK) error {
+		sdk.retryPolicy = policy
+		return nil
+	}
+}
+
+// UsingCache will store the result of each update check in the user's cache
+// dir (os.UserCacheDir()/usrbin/<app>), and GetUpdateInfo will return it
+// without making a request until it's older than ttl. a GitHub update checker
+// also caches release responses there, and makes conditional requests. when
+// the update checker is rate limited, the reset is stored there too
+func UsingCache(app string, ttl time.Duration) Option {
+	return func(sdk *SDK) error {
+		if app == "" || strings.ContainsAny(app, `/\`) {
+			return errors.Errorf("invalid app name: %q", app)
+		}
+
+		dir, err := defaultCacheDir(app)
+		if err != nil {
+			return errors.Wrap(err, "default cache dir")
+		}
+
+		sdk.updateInfoCache = &updateInfoCache{
+			dir: dir,
+			ttl: ttl,
+		}
+		return nil
+	}
+}
+
+// UsingCacheDir is UsingCache, storing the cache in dir
+func UsingCacheDir(dir string, ttl time.Duration) Option {
+	return func(sdk *SDK) error {
+		sdk.updateInfoCache = &updateInfoCache{
+			dir: dir,
+			ttl: ttl,
+		}
+		return nil
+	}
+}
+
+// UsingChannel will offer the releases in the channel: stable (the default),
+// rc, beta, alpha, nightly or any other name. a prerelease is in a channel when
+// its semver prerelease starts with the channel's name, see updatechecker.InChannel.
+// when this isn't set, the channel is read from USRBIN_CHANNEL, so that users
+// can opt in
+func UsingChannel(channel string) Option {
+	return func(sdk *SDK) error {
+		sdk.channel = channel
+		return nil
+	}
+}
+
+// UsingVersionConstraint will only offer the versions that satisfy
+// constraint, such as ">=1.4, <2.0" to stay on the 1.x line. see
+// updatechecker.SatisfiesConstraint. when a newer version doesn't satisfy
+// the constraint, it's reported in UpdateInfo.OutsideConstraintVersion, but
+// it's never upgraded to. that's reported even when the current version is
+// the latest that satisfies the constraint, with an empty LatestVersion
+func UsingVersionConstraint(constraint string) Option {
+	return func(sdk *SDK) error {
+		parsed, err := semver.NewConstraint(constraint)
+		if err != nil {
+			return errors.Wrap(err, "parse version constraint")
+		}
+
+		sdk.versionConstraint = constraint
+		sdk.parsedVersionConstraint = parsed
+		return nil
+	}
+}
+
+// UsingBackupRetention will keep the keep newest backups of the executables
+// that upgrades replaced, named like mytool.old-1.4.0 next to the executable,
+// and remove older ones. by default 1 is kept, so that Rollback can undo the
+// last upgrade. 0 doesn't keep any
+func UsingBackupRetention(keep int) Option {
+	return func(sdk *SDK) error {
+		if keep < 0 {
+			return errors.New("backup retention can't be negative")
+		}
+
+		sdk.backupRetention = keep
+		return nil
+	}
+}
+
+// UsingHealthCheck will run the downloaded executable with check before an
+// upgrade replaces the current one, and abort the upgrade when the check
+// fails. pass DefaultHealthCheck to run it with --version
+func UsingHealthCheck(check HealthCheck) Option {
+	return func(sdk *SDK) error {
+		sdk.healthCheck = &check
+		return nil
+	}
+}
+
 func New(version string, opts ...Option) (*SDK, error) {
 	sdk := SDK{
-		version: version,
+		version:   version,
+		rateLimit: &rateLimit{},
 	}
 
 	sdk.httpTimeout = 10 * time.Second
+	sdk.retryPolicy = retry.DefaultPolicy
+	sdk.channel = os.Getenv(updatechecker.ChannelEnvVar)
+	sdk.backupRetention = defaultBackupRetention
 
 	if err := sdk.parseOptions(opts); err != nil {
 		return nil, err
 	}
 
+	if sdk.updateInfoCache != nil {
+		sdk.rateLimit.dir = sdk.updateInfoCache.dir
+	}
+
+	// the update checker is created once all of the options have been
+	// parsed, so that it doesn't matter which order they are passed in
+	if sdk.newUpdateChecker != nil {
+		sdk.updateChecker = sdk.newUpdateChecker(&sdk)
+
+		// a second update checker finds the newest version, so that one
+		// outside of the constraint can be reported
+		if sdk.parsedVersionConstraint != nil {
+			unconstrained := sdk
+			unconstrained.parsedVersionConstraint = nil
+			sdk.unconstrainedUpdateChecker = sdk.newUpdateChecker(&unconstrained)
+		}
+	}
+
 	return &sdk, nil
 }
 
@@ -1,9 +1,12 @@
 package usrbin
 
 import (
+	"net/http"
 	"time"
 
+	"github.com/Masterminds/semver"
 	"github.com/usrbinapp/usrbin-go/pkg/pkgmgr"
+	"github.com/usrbinapp/usrbin-go/pkg/retry"
 	"github.com/usrbinapp/usrbin-go/pkg/updatechecker"
 )
 
@@ -12,9 +15,38 @@ type Logger interface {
 }
 
 type SDK struct {
-	version                 string
-	updateChecker           updatechecker.UpdateChecker
-	externalPackageManagers []pkgmgr.ExternalPackageManager
-	httpTimeout             time.Duration
-	logger                  Logger
+	version                    string
+	updateChecker              updatechecker.UpdateChecker
+	unconstrainedUpdateChecker updatechecker.UpdateChecker
+	newUpdateChecker           func(sdk *SDK) updatechecker.UpdateChecker
+	externalPackageManagers    []pkgmgr.ExternalPackageManager
+	httpTimeout                time.Duration
+	httpClient                 *http.Client
+	retryPolicy                retry.Policy
+	channel                    string
+	versionConstraint          string
+	parsedVersionConstraint    *semver.Constraints
+	backupRetention            int
+	healthCheck                *HealthCheck
+	logger                     Logger
+	rateLimit                  *rateLimit
+	updateInfoCache            *updateInfoCache
+}
+
+// logf will write to the logger, when there is one
+func (s SDK) logf(format string, v ...interface{}) {
+	if s.logger == nil {
+		return
+	}
+
+	s.logger.Printf(format, v...)
+}
+
+// cacheKey is what an update check is made for
+func (s SDK) cacheKey() updateInfoCacheKey {
+	return updateInfoCacheKey{
+		CurrentVersion:    s.version,
+		Channel:           s.channel,
+		VersionConstraint: s.versionConstraint,
+	}
 }
@@ -0,0 +1,49 @@
+package usrbin
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/usrbinapp/usrbin-go/pkg/updatechecker"
+)
+
+var (
+	ErrListVersionsNotSupported = errors.New("update checker can't list versions")
+)
+
+// ListVersions will return every version that the update checker offers,
+// in the channel and satisfying the version constraint, sorted by semver
+// with the oldest first. ErrListVersionsNotSupported is returned when the
+// update checker isn't an updatechecker.VersionLister
+func (s SDK) ListVersions() ([]updatechecker.VersionInfo, error) {
+	return s.ListVersionsContext(context.Background())
+}
+
+// ListVersionsContext is ListVersions, stopping when ctx is done
+func (s SDK) ListVersionsContext(ctx context.Context) ([]updatechecker.VersionInfo, error) {
+	lister, ok := s.updateChecker.(updatechecker.VersionLister)
+	if !ok {
+		return nil, ErrListVersionsNotSupported
+	}
+
+	if rateLimitedErr := s.rateLimit.active(time.Now()); rateLimitedErr != nil {
+		return nil, rateLimitedErr
+	}
+
+	listCtx, cancel := context.WithTimeout(ctx, s.httpTimeout)
+	defer cancel()
+
+	versions, err := lister.ListVersionsContext(listCtx)
+	if err != nil {
+		var rateLimitedErr *updatechecker.RateLimitedError
+		if errors.As(err, &rateLimitedErr) {
+			if err := s.rateLimit.set(rateLimitedErr); err != nil {
+				s.logf("failed to cache rate limit: %v", err)
+			}
+		}
+		return nil, errors.Wrap(err, "list versions")
+	}
+
+	return versions, nil
+}
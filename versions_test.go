@@ -0,0 +1,42 @@
+package usrbin
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ListVersions(t *testing.T) {
+	req := require.New(t)
+
+	dir, err := ioutil.TempDir("", "usrbin")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+
+	for _, version := range []string{"v1.10.0", "v1.4.0", "v1.9.0", "v2.0.0-beta.1", "v2.0.0"} {
+		req.NoError(os.Mkdir(filepath.Join(dir, version), 0755))
+	}
+
+	sdk, err := New("1.4.0", UsingLocalUpdateChecker(dir), UsingVersionConstraint("<2.0"))
+	req.NoError(err)
+
+	versions, err := sdk.ListVersions()
+	req.NoError(err)
+
+	got := []string{}
+	for _, v := range versions {
+		got = append(got, v.Version)
+	}
+	assert.Equal(t, []string{"v1.4.0", "v1.9.0", "v1.10.0"}, got)
+
+	sdk = &SDK{
+		version:       "1.4.0",
+		updateChecker: delayedUpdateChecker{},
+	}
+	_, err = sdk.ListVersions()
+	assert.ErrorIs(t, err, ErrListVersionsNotSupported)
+}